@@ -0,0 +1,357 @@
+package main
+
+import (
+	"box/dumper"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// apiDump is dump of api with its last results
+type apiDump struct {
+	Name        string      `json:"name"`
+	Type        dumper.Type `json:"type"`
+	Tags        []string    `json:"tags,omitempty"`
+	Periods     string      `json:"periods"`
+	LastRun     *dumpResult `json:"last_run,omitempty"`
+	LastSuccess *dumpResult `json:"last_success,omitempty"`
+	//zero when dump is never needed again
+	NextDue *time.Time `json:"next_due,omitempty"`
+	Due     bool       `json:"due"`
+	Running bool       `json:"running"`
+	Files   int        `json:"files"`
+	Size    int64      `json:"size"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// apiArtifact is stored dump file of api
+type apiArtifact struct {
+	Period    string    `json:"period"`
+	FileName  string    `json:"file_name"`
+	Reference string    `json:"reference"`
+	Time      time.Time `json:"time"`
+	Size      int64     `json:"size"`
+	Sha256    string    `json:"sha256,omitempty"`
+	Log       bool      `json:"log"`
+}
+
+// apiJob is job with results of its dumps from catalog
+type apiJob struct {
+	job
+	Results []dumpResult `json:"results,omitempty"`
+}
+
+func (s *server) serveApi(w http.ResponseWriter, r *http.Request, path []string) {
+	if len(s.config.Server.ApiTokens) == 0 {
+		apiError(w, http.StatusForbidden, "api is disabled, set server.api-tokens")
+		return
+	}
+	if !s.apiAuthorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="box"`)
+		apiError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	switch {
+	case len(path) == 1 && path[0] == "dumps":
+		s.apiDumps(w, r)
+	case len(path) == 2 && path[0] == "dumps":
+		s.apiDump(w, r, path[1])
+	case len(path) == 3 && path[0] == "dumps" && path[2] == "artifacts":
+		s.apiArtifacts(w, r, path[1])
+	case len(path) == 3 && path[0] == "dumps" && path[2] == "checksum":
+		s.apiArtifactFile(w, r, path[1], (*dumper.Artifact).ChecksumPath)
+	case len(path) == 3 && path[0] == "dumps" && path[2] == "log":
+		s.apiArtifactFile(w, r, path[1], (*dumper.Artifact).LogPath)
+	case len(path) == 3 && path[0] == "dumps" && (path[2] == "run" || path[2] == "verify" || path[2] == "prune"):
+		s.apiStart(w, r, path[1], path[2])
+	case len(path) == 1 && path[0] == "jobs":
+		s.apiJobs(w, r)
+	case len(path) == 2 && path[0] == "jobs":
+		s.apiJob(w, r, path[1])
+	case len(path) == 3 && path[0] == "jobs" && path[2] == "output":
+		s.apiJobOutput(w, r, path[1])
+	default:
+		apiError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// apiAuthorized checks bearer token against all configured tokens
+func (s *server) apiAuthorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	authorized := false
+	for _, configured := range s.config.Server.ApiTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(configured)) == 1 {
+			authorized = true
+		}
+	}
+	return authorized
+}
+
+func writeJson(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		log.Errorf("unable to write api response: %s", err)
+	}
+}
+
+func apiError(w http.ResponseWriter, status int, message string) {
+	writeJson(w, status, map[string]string{"error": message})
+}
+
+// apiMethod rejects requests with other method than method
+func apiMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method || (method == http.MethodGet && r.Method == http.MethodHead) {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	apiError(w, http.StatusMethodNotAllowed, "method not allowed")
+	return false
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (s *server) apiDumpOf(dump dumper.Configuration, results []dumpResult) apiDump {
+	d, err := dumper.New(s.config.Global, dump)
+	view, _ := s.dumpView(dump, d, results)
+	if err != nil {
+		view.Error = err.Error()
+	}
+
+	result := apiDump{
+		Name:        view.Name,
+		Type:        view.Type,
+		Tags:        view.Tags,
+		Periods:     view.Periods,
+		LastRun:     view.Last,
+		LastSuccess: view.LastSuccess,
+		Due:         view.Due,
+		Running:     view.Running,
+		Files:       view.Count,
+		Size:        view.Size,
+		Error:       view.Error,
+	}
+	if !view.NextDue.IsZero() {
+		result.NextDue = &view.NextDue
+	}
+	return result
+}
+
+func (s *server) apiDumps(w http.ResponseWriter, r *http.Request) {
+	if !apiMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	results, err := readCatalog(catalogFileName(s.config), nil)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	dumps := make([]apiDump, 0, len(s.config.Dumps))
+	for _, dump := range s.config.Dumps {
+		dumps = append(dumps, s.apiDumpOf(dump, results))
+	}
+
+	writeJson(w, http.StatusOK, dumps)
+}
+
+func (s *server) apiDump(w http.ResponseWriter, r *http.Request, name string) {
+	if !apiMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	dump, ok := s.dumpConfiguration(name)
+	if !ok {
+		apiError(w, http.StatusNotFound, fmt.Sprintf("dump %s not found", name))
+		return
+	}
+
+	results, err := readCatalog(catalogFileName(s.config), []string{escapePattern(name)})
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusOK, s.apiDumpOf(dump, results))
+}
+
+// apiArtifacts lists stored dumps, only of period query parameter when it is set
+func (s *server) apiArtifacts(w http.ResponseWriter, r *http.Request, name string) {
+	if !apiMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	d, err := s.findDump(name)
+	if err != nil {
+		apiError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	artifacts, err := d.Inventory()
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+
+	result := make([]apiArtifact, 0, len(artifacts))
+	for _, a := range artifacts {
+		if len(period) != 0 && a.Period != period {
+			continue
+		}
+		artifact := apiArtifact{
+			Period:    a.Period,
+			FileName:  a.FileName,
+			Reference: a.Reference(),
+			Time:      a.Time,
+			Size:      a.Size,
+		}
+		if checksums, err := a.Checksums(); err == nil {
+			artifact.Sha256 = checksums[dumper.HashSha256]
+		}
+		if _, err := os.Stat(a.LogPath()); err == nil {
+			artifact.Log = true
+		}
+		result = append(result, artifact)
+	}
+
+	writeJson(w, http.StatusOK, result)
+}
+
+// apiArtifactFile serves checksum or log file of stored dump of file query parameter
+func (s *server) apiArtifactFile(w http.ResponseWriter, r *http.Request, name string, path func(*dumper.Artifact) string) {
+	if !apiMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	a, err := s.findArtifact(name, r.URL.Query().Get("file"))
+	if err != nil {
+		apiError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	content, err := os.ReadFile(path(&a))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			apiError(w, http.StatusNotFound, fmt.Sprintf("%s has no such file", a.Reference()))
+			return
+		}
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write(content)
+}
+
+// apiStart starts run, verify or prune job of dump, run makes dump even when it is not needed with force=true
+func (s *server) apiStart(w http.ResponseWriter, r *http.Request, name, command string) {
+	if !apiMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	if _, ok := s.dumpConfiguration(name); !ok {
+		apiError(w, http.StatusNotFound, fmt.Sprintf("dump %s not found", name))
+		return
+	}
+
+	var flags []string
+	if force := r.URL.Query().Get("force"); len(force) != 0 {
+		value, err := strconv.ParseBool(force)
+		if err != nil || command != "run" {
+			apiError(w, http.StatusBadRequest, "force must be true or false, it is supported by run only")
+			return
+		}
+		if value {
+			flags = append(flags, "--force")
+		}
+	}
+
+	j, err := s.jobs.start(command, []string{name}, flags)
+	if errors.Is(err, errJobRunning) {
+		writeJson(w, http.StatusConflict, map[string]interface{}{
+			"error": fmt.Sprintf("dump %s is in running job %s", name, j.Id),
+			"job":   j,
+		})
+		return
+	}
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Location", "/api/jobs/"+url.PathEscape(j.Id))
+	writeJson(w, http.StatusAccepted, apiJob{job: j})
+}
+
+func (s *server) apiJobs(w http.ResponseWriter, r *http.Request) {
+	if !apiMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJson(w, http.StatusOK, s.jobs.list())
+}
+
+// apiJob returns job, run job has results of its dumps when it is finished
+func (s *server) apiJob(w http.ResponseWriter, r *http.Request, id string) {
+	if !apiMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	j, ok := s.jobs.get(id)
+	if !ok {
+		apiError(w, http.StatusNotFound, fmt.Sprintf("job %s not found", id))
+		return
+	}
+
+	result := apiJob{job: j}
+
+	if j.Command == "run" && j.End != nil {
+		patterns := make([]string, 0, len(j.Dumps))
+		for _, name := range j.Dumps {
+			patterns = append(patterns, escapePattern(name))
+		}
+		results, err := readCatalog(catalogFileName(s.config), patterns)
+		if err != nil {
+			apiError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, dumpResult := range results {
+			if !dumpResult.Start.Before(j.Start) && !dumpResult.Start.After(*j.End) {
+				result.Results = append(result.Results, dumpResult)
+			}
+		}
+	}
+
+	writeJson(w, http.StatusOK, result)
+}
+
+func (s *server) apiJobOutput(w http.ResponseWriter, r *http.Request, id string) {
+	if !apiMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	j, ok := s.jobs.get(id)
+	if !ok {
+		apiError(w, http.StatusNotFound, fmt.Sprintf("job %s not found", id))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(j.Output))
+}
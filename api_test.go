@@ -0,0 +1,147 @@
+package main
+
+import (
+	"box/configuration"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+const testApiToken = "0123456789abcdef"
+
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+func TestApi_auth(t *testing.T) {
+	s := newTestServer(t, configuration.ServerConfiguration{}, "true")
+	if w := serve(s, http.MethodGet, "/api/dumps", bearer(testApiToken)); w.Code != http.StatusForbidden {
+		t.Errorf("expected disabled api, got %d", w.Code)
+	}
+
+	s = newTestServer(t, configuration.ServerConfiguration{Username: "admin", Password: "secret", ApiTokens: []string{"other-token-0123456", testApiToken}}, "true")
+
+	for _, header := range []http.Header{nil, bearer("wrong"), bearer(""), {"Authorization": {testApiToken}}, basicAuth("admin", "secret")} {
+		w := serve(s, http.MethodGet, "/api/dumps", header)
+		if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%v: expected %d, got %d", header, http.StatusUnauthorized, w.Code)
+		}
+	}
+
+	w := serve(s, http.MethodGet, "/api/dumps", bearer(testApiToken))
+	var dumps []apiDump
+	if err := json.Unmarshal(w.Body.Bytes(), &dumps); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("expected dumps, got %d %s", w.Code, w.Body)
+	}
+	if len(dumps) != 1 || dumps[0].Name != "files" || dumps[0].Files != 1 {
+		t.Errorf("unexpected dumps %+v", dumps)
+	}
+}
+
+func TestApi_artifacts(t *testing.T) {
+	s := newTestServer(t, configuration.ServerConfiguration{ApiTokens: []string{testApiToken}}, "true")
+
+	w := serve(s, http.MethodGet, "/api/dumps/files/artifacts?period=latest", bearer(testApiToken))
+	var artifacts []apiArtifact
+	if err := json.Unmarshal(w.Body.Bytes(), &artifacts); err != nil || len(artifacts) != 1 || !artifacts[0].Log {
+		t.Errorf("expected latest with log, got %d %s", w.Code, w.Body)
+	}
+	if w := serve(s, http.MethodGet, "/api/dumps/files/artifacts?period=daily", bearer(testApiToken)); w.Body.String() != "[]\n" {
+		t.Errorf("expected no daily dumps, got %s", w.Body)
+	}
+
+	if w := serve(s, http.MethodGet, "/api/dumps/files/log?file=latest", bearer(testApiToken)); w.Code != http.StatusOK || w.Body.String() != "dump log" {
+		t.Errorf("expected log, got %d %s", w.Code, w.Body)
+	}
+
+	for _, path := range []string{
+		//latest has no checksum file
+		"/api/dumps/files/checksum?file=latest",
+		"/api/dumps/files/log?file=daily",
+		"/api/dumps/files/log?file=../../tmp",
+		"/api/dumps/files/checksum",
+		"/api/dumps/other/log?file=latest",
+		"/api/dumps/other",
+		"/api/jobs/unknown",
+	} {
+		if w := serve(s, http.MethodGet, path, bearer(testApiToken)); w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected %d, got %d", path, http.StatusNotFound, w.Code)
+		}
+	}
+}
+
+func TestApi_start(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("jobs run shell script")
+	}
+	s := newTestServer(t, configuration.ServerConfiguration{ApiTokens: []string{testApiToken}}, "exec sleep 5")
+	defer s.jobs.stop()
+
+	for _, path := range []string{
+		"/api/dumps/files/run?force=maybe",
+		"/api/dumps/files/verify?force=true",
+		"/api/dumps/files/prune?force=false",
+	} {
+		if w := serve(s, http.MethodPost, path, bearer(testApiToken)); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected %d, got %d", path, http.StatusBadRequest, w.Code)
+		}
+	}
+	if w := serve(s, http.MethodGet, "/api/dumps/files/run", bearer(testApiToken)); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected GET refused, got %d", w.Code)
+	}
+	if w := serve(s, http.MethodPost, "/api/dumps/other/run", bearer(testApiToken)); w.Code != http.StatusNotFound {
+		t.Errorf("expected unknown dump, got %d", w.Code)
+	}
+
+	w := serve(s, http.MethodPost, "/api/dumps/files/run?force=true", bearer(testApiToken))
+	var started apiJob
+	if err := json.Unmarshal(w.Body.Bytes(), &started); err != nil || w.Code != http.StatusAccepted {
+		t.Fatalf("expected started job, got %d %s", w.Code, w.Body)
+	}
+	if !reflect.DeepEqual(started.Args, []string{"--force"}) || w.Header().Get("Location") != "/api/jobs/"+started.Id {
+		t.Errorf("unexpected job %+v, location %s", started.job, w.Header().Get("Location"))
+	}
+
+	w = serve(s, http.MethodPost, "/api/dumps/files/prune", bearer(testApiToken))
+	var conflict struct {
+		Error string `json:"error"`
+		Job   job    `json:"job"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &conflict); err != nil || w.Code != http.StatusConflict || conflict.Job.Id != started.Id {
+		t.Errorf("expected conflict with job %s, got %d %s", started.Id, w.Code, w.Body)
+	}
+}
+
+func TestApi_jobResults(t *testing.T) {
+	s := newTestServer(t, configuration.ServerConfiguration{ApiTokens: []string{testApiToken}}, "true")
+
+	start := time.Now().Add(-time.Hour)
+	end := start.Add(time.Minute)
+	for _, result := range []dumpResult{
+		{Name: "files", Status: statusFailed, Start: start.Add(-time.Second)},
+		{Name: "files", Status: statusSuccess, Start: start.Add(time.Second)},
+		{Name: "other", Status: statusSuccess, Start: start.Add(time.Second)},
+		{Name: "files", Status: statusSkipped, Start: end.Add(time.Second)},
+	} {
+		if err := appendCatalog(catalogFileName(s.config), result); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.jobs.jobs = append(s.jobs.jobs, &runningJob{
+		job:    job{Id: "finished", Command: "run", Dumps: []string{"files"}, Status: statusSuccess, Start: start, End: &end},
+		output: &tailBuffer{size: maxJobOutput},
+	})
+
+	w := serve(s, http.MethodGet, "/api/jobs/finished", bearer(testApiToken))
+	var result apiJob
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("expected job, got %d %s", w.Code, w.Body)
+	}
+	if len(result.Results) != 1 || result.Results[0].Status != statusSuccess || result.Results[0].Name != "files" {
+		t.Errorf("expected result of job time window, got %+v", result.Results)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"box/configuration"
+	"box/dumper"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// catalogLockWait is how long appending waits for another box process appending to catalog
+const catalogLockWait = 10 * time.Second
+
+// maxCatalogSize is size of catalog rotated to catalog.1 (replacing older one), history keeps both files
+var maxCatalogSize int64 = 4 * 1024 * 1024
+
+// catalogFileName is JSON lines file of all dump results, one line per dump run
+func catalogFileName(config *configuration.Configuration) string {
+	return filepath.Join(config.Global.Path, ".box-catalog.jsonl")
+}
+
+// appendCatalog writes result as the last line of catalog
+func appendCatalog(fileName string, result dumpResult) error {
+	line, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	lock, err := dumper.AcquireLock(context.Background(), fileName+".lock", true, catalogLockWait)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	if info, err := os.Stat(fileName); err == nil && info.Size()+int64(len(line)) >= maxCatalogSize {
+		if err := os.Rename(fileName, rotatedCatalogFileName(fileName)); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// rotatedCatalogFileName is previous part of catalog
+func rotatedCatalogFileName(fileName string) string {
+	return fileName + ".1"
+}
+
+// readCatalog returns results of dumps matching name patterns in order they were written, all when there are no patterns,
+// rotated part of catalog is read first
+func readCatalog(fileName string, patterns []string) ([]dumpResult, error) {
+	results, err := readCatalogFile(rotatedCatalogFileName(fileName), patterns, nil)
+	if err != nil {
+		return nil, err
+	}
+	return readCatalogFile(fileName, patterns, results)
+}
+
+// readCatalogFile appends results of dumps matching name patterns in file to results
+func readCatalogFile(fileName string, patterns []string, results []dumpResult) ([]dumpResult, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return results, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var result dumpResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			//line may be cut by crash while it was written
+			log.Warnf("%s:%d: %s", fileName, line, err)
+			continue
+		}
+		if matchesAny(result.Name, patterns) {
+			results = append(results, result)
+		}
+	}
+
+	return results, scanner.Err()
+}
+
+func matchesAny(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func historyCommand(opts *options, args []string) error {
+	flags := newCommandFlags("history", "[dump name patterns...]")
+	limit := flags.Int("limit", 20, "show the last `n` results, 0 for all")
+	status := flags.String("status", "", "show only results with `status`: success, skipped, failed or aborted")
+	asJson := flags.Bool("json", false, "print results as JSON lines with checksums and files")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := readConfiguration(opts)
+	if err != nil {
+		return err
+	}
+
+	results, err := readCatalog(catalogFileName(config), flags.Args())
+	if err != nil {
+		return err
+	}
+
+	if len(*status) != 0 {
+		filtered := results[:0]
+		for _, result := range results {
+			if result.Status == *status {
+				filtered = append(filtered, result)
+			}
+		}
+		results = filtered
+	}
+
+	if *limit > 0 && len(results) > *limit {
+		results = results[len(results)-*limit:]
+	}
+
+	if *asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, result := range results {
+			if err := encoder.Encode(result); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "START\tNAME\tTYPE\tSTATUS\tDURATION\tSIZE\tEXIT\tFILES\tROTATED\tPRUNED\tERROR")
+	for _, result := range results {
+		size := "-"
+		if result.Size > 0 {
+			size = formatSize(result.Size)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\n",
+			result.Start.Local().Format("2006-01-02 15:04:05"), result.Name, result.Type, result.Status,
+			time.Duration(result.Seconds*float64(time.Second)).Round(time.Millisecond), size,
+			result.ExitStatus, len(result.Files), len(result.Rotated), len(result.Pruned), result.Error)
+	}
+
+	return w.Flush()
+}
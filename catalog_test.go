@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func resultNames(results []dumpResult) []string {
+	var names []string
+	for _, result := range results {
+		names = append(names, result.Name)
+	}
+	return names
+}
+
+func TestReadCatalog(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), ".box-catalog.jsonl")
+
+	if results, err := readCatalog(fileName, nil); err != nil || len(results) != 0 {
+		t.Fatalf("expected empty catalog, got %v, %v", results, err)
+	}
+
+	for _, name := range []string{"pg", "pg_old", "mysql"} {
+		if err := appendCatalog(fileName, dumpResult{Name: name, Status: statusSuccess, Checksums: map[string]string{"sha256": "abc"}, Pruned: []string{"old"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//line cut by crash and empty line are skipped
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString("\n{\"name\":\"cut\",\"sta"); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	tests := []struct {
+		patterns []string
+		want     []string
+	}{
+		{patterns: nil, want: []string{"pg", "pg_old", "mysql"}},
+		{patterns: []string{"pg"}, want: []string{"pg"}},
+		{patterns: []string{"pg*"}, want: []string{"pg", "pg_old"}},
+		{patterns: []string{"mysql", "pg_*"}, want: []string{"pg_old", "mysql"}},
+		{patterns: []string{"other"}, want: nil},
+	}
+	for _, tt := range tests {
+		results, err := readCatalog(fileName, tt.patterns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := resultNames(results); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("readCatalog(%v) = %v, want %v", tt.patterns, got, tt.want)
+		}
+	}
+
+	results, _ := readCatalog(fileName, []string{"pg"})
+	if results[0].Status != statusSuccess || results[0].Checksums["sha256"] != "abc" || !reflect.DeepEqual(results[0].Pruned, []string{"old"}) {
+		t.Errorf("unexpected result %+v", results[0])
+	}
+}
+
+func TestAppendCatalog_rotate(t *testing.T) {
+	size := maxCatalogSize
+	maxCatalogSize = 200
+	defer func() {
+		maxCatalogSize = size
+	}()
+
+	fileName := filepath.Join(t.TempDir(), ".box-catalog.jsonl")
+	var names []string
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		if err := appendCatalog(fileName, dumpResult{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+
+	for _, name := range []string{fileName, rotatedCatalogFileName(fileName)} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() >= maxCatalogSize {
+			t.Errorf("%s has %d bytes, want less than %d", name, info.Size(), maxCatalogSize)
+		}
+	}
+
+	results, err := readCatalog(fileName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := resultNames(results)
+	if len(got) == 0 || len(got) >= len(names) || !reflect.DeepEqual(got, names[len(names)-len(got):]) {
+		t.Errorf("readCatalog() = %v, want the newest of %v", got, names)
+	}
+}
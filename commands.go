@@ -0,0 +1,380 @@
+package main
+
+import (
+	"box/configuration"
+	"box/dumper"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ",")
+}
+
+func (list *stringList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+// selection holds dump selection flags shared by commands
+type selection struct {
+	tags   stringList
+	groups stringList
+}
+
+func (s *selection) register(flags *flag.FlagSet) {
+	flags.Var(&s.tags, "tag", "select dumps with `tag` (repeatable)")
+	flags.Var(&s.groups, "group", "select dumps of configured `group` (repeatable)")
+}
+
+// dumps returns configured dumps selected by name patterns, groups and tags
+func (s *selection) dumps(config *configuration.Configuration, patterns []string) ([]dumper.Configuration, error) {
+	dumps, err := config.Select(patterns, s.tags, s.groups)
+	if err != nil {
+		return nil, err
+	}
+	//exact names are usually typed by hand, a typo must not silently skip the dump
+	for _, pattern := range patterns {
+		if !strings.ContainsAny(pattern, `*?[\`) && !hasDump(config, pattern) {
+			return nil, fmt.Errorf("unknown dump %s", pattern)
+		}
+	}
+	if len(dumps) == 0 && (len(patterns) > 0 || len(s.tags) > 0 || len(s.groups) > 0) {
+		return nil, errors.New("no dumps match selection")
+	}
+	return dumps, nil
+}
+
+func hasDump(config *configuration.Configuration, name string) bool {
+	for _, dump := range config.Dumps {
+		if dump.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func newCommandFlags(name, arguments string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: box [global flags] %s [flags] %s\n", name, arguments)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func listCommand(opts *options, args []string) error {
+	sel := selection{}
+	flags := newCommandFlags("list", "[dump name patterns...]")
+	sel.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := readConfiguration(opts)
+	if err != nil {
+		return err
+	}
+
+	dumps, err := sel.dumps(config, flags.Args())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tTAGS\tPERIODS\tPATH")
+
+	for _, dump := range dumps {
+		rootPath := ""
+		if d, err := dumper.New(config.Global, dump); err == nil {
+			rootPath = d.RootPath()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", dump.Name, dump.Type, strings.Join(dump.Tags, ","), enabledPeriods(dump), rootPath)
+	}
+
+	return w.Flush()
+}
+
+func statusCommand(opts *options, args []string) error {
+	sel := selection{}
+	flags := newCommandFlags("status", "[dump name patterns...]")
+	sel.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := readConfiguration(opts)
+	if err != nil {
+		return err
+	}
+
+	dumps, err := sel.dumps(config, flags.Args())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPERIOD\tNEWEST\tAGE\tSIZE\tCOUNT\tDUE")
+
+	for _, dump := range dumps {
+		d, err := dumper.New(config.Global, dump)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t-\t-\t%s\n", dump.Name, err)
+			continue
+		}
+
+		artifacts, err := d.Inventory()
+		if err != nil {
+			return fmt.Errorf("%s: %s", dump.Name, err)
+		}
+
+		due := "no"
+		if d.DumpNeeded() {
+			due = "yes"
+		}
+
+		if len(artifacts) == 0 {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t-\t0\t%s\n", dump.Name, due)
+			continue
+		}
+
+		counts := make(map[string]int)
+		for _, a := range artifacts {
+			counts[a.Period]++
+		}
+
+		//inventory is sorted newest first inside of period
+		seen := make(map[string]bool)
+		for _, a := range artifacts {
+			if seen[a.Period] {
+				continue
+			}
+			seen[a.Period] = true
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+				dump.Name, a.Period, a.FileName, formatAge(time.Since(a.Time)), formatSize(a.Size), counts[a.Period], due)
+		}
+	}
+
+	return w.Flush()
+}
+
+func verifyCommand(opts *options, args []string) error {
+	sel := selection{}
+	flags := newCommandFlags("verify", "[dump name patterns...]")
+	sel.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := readConfiguration(opts)
+	if err != nil {
+		return err
+	}
+
+	dumps, err := sel.dumps(config, flags.Args())
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+
+	for _, dump := range dumps {
+		d, err := dumper.New(config.Global, dump)
+		if err != nil {
+			return fmt.Errorf("%s: %s", dump.Name, err)
+		}
+
+		results, err := d.Verify()
+		if err != nil {
+			return fmt.Errorf("%s: %s", dump.Name, err)
+		}
+
+		paths := make([]string, 0, len(results))
+		for path := range results {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			if err := results[path]; err != nil {
+				failed++
+				log.Errorf("%s (%s) %s: %s", dump.Name, dump.Type, path, err)
+			} else {
+				log.Infof("%s (%s) %s: ok", dump.Name, dump.Type, path)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d dumps failed verification", failed)
+	}
+
+	return nil
+}
+
+func pruneCommand(opts *options, args []string) error {
+	sel := selection{}
+	flags := newCommandFlags("prune", "[dump name patterns...]")
+	sel.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := readConfiguration(opts)
+	if err != nil {
+		return err
+	}
+
+	dumps, err := sel.dumps(config, flags.Args())
+	if err != nil {
+		return err
+	}
+
+	if !opts.dryRun {
+		//retention and quotas see all dumps of path, running dumps must finish first
+		lock, err := acquireGlobalLock(context.Background(), config, true)
+		if err != nil {
+			return err
+		}
+		defer releaseGlobalLock(lock)
+	}
+
+	var errs []error
+
+	for _, dump := range dumps {
+		d, err := dumper.New(config.Global, dump)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", dump.Name, err))
+			continue
+		}
+		if err := d.Prune(); err != nil {
+			log.Errorf("%s (%s) prune error: %s", dump.Name, dump.Type, err)
+			errs = append(errs, fmt.Errorf("%s: %s", dump.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func restoreCommand(opts *options, args []string) error {
+	flags := newCommandFlags("restore", "<dump name> [latest|period|period/file]")
+	destination := flags.String("to", "", "destination file `path` (required)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() < 1 || flags.NArg() > 2 || len(*destination) == 0 {
+		flags.Usage()
+		return errors.New("dump name and --to are required")
+	}
+
+	config, err := readConfiguration(opts)
+	if err != nil {
+		return err
+	}
+
+	name := flags.Arg(0)
+	reference := dumper.PeriodLatest
+	if flags.NArg() == 2 {
+		reference = flags.Arg(1)
+	}
+
+	for _, dump := range config.Dumps {
+		if dump.Name != name {
+			continue
+		}
+
+		d, err := dumper.New(config.Global, dump)
+		if err != nil {
+			return err
+		}
+
+		if opts.dryRun {
+			a, err := d.Find(reference)
+			if err != nil {
+				return err
+			}
+			log.Infof("[dry-run] %s (%s) restore %s to %s", dump.Name, dump.Type, a.Path, *destination)
+			return nil
+		}
+
+		a, err := d.Restore(reference, *destination)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("%s (%s) %s restored to %s", dump.Name, dump.Type, a.Path, *destination)
+		return nil
+	}
+
+	return fmt.Errorf("dump %s not configured", name)
+}
+
+func configCommand(opts *options, args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return errors.New("usage: box config check")
+	}
+
+	config, err := configuration.Read(opts.configFileName)
+	if err == nil {
+		err = errors.Join(config.CheckExecutables()...)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return &exitError{exitUsageError, errors.New("configuration has errors")}
+	}
+
+	fmt.Printf("%s: ok, %d dumps\n", opts.configFileName, len(config.Dumps))
+
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func enabledPeriods(dump dumper.Configuration) string {
+	var periods []string
+	for _, period := range []struct {
+		name    string
+		enabled bool
+	}{
+		{dumper.PeriodLatest, dump.Latest},
+		{"hourly", dump.Hourly},
+		{"daily", dump.Daily},
+		{"weekly", dump.Weekly},
+		{"monthly", dump.Monthly},
+		{"yearly", dump.Yearly},
+	} {
+		if period.enabled {
+			periods = append(periods, period.name)
+		}
+	}
+	return strings.Join(periods, ",")
+}
+
+func formatAge(age time.Duration) string {
+	switch {
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	}
+}
+
+func formatSize(size int64) string {
+	return dumper.Size(size).String()
+}
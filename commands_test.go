@@ -0,0 +1,103 @@
+package main
+
+import (
+	"box/configuration"
+	"box/dumper"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelection_dumps(t *testing.T) {
+	config := &configuration.Configuration{
+		Dumps: []dumper.Configuration{
+			{Name: "db-main", Tags: []string{"prod"}},
+			{Name: "db-test", Tags: []string{"test"}},
+			{Name: "files", Tags: []string{"prod"}},
+			{Name: "db*"},
+		},
+		Groups: map[string][]string{"databases": {"db-*"}},
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr string
+	}{
+		{name: "all", want: []string{"db-main", "db-test", "files", "db*"}},
+		{name: "exact name", args: []string{"files"}, want: []string{"files"}},
+		{name: "glob", args: []string{"db-*"}, want: []string{"db-main", "db-test"}},
+		{name: "escaped glob", args: []string{`db\*`}, want: []string{"db*"}},
+		{name: "tag", args: []string{"--tag", "prod"}, want: []string{"db-main", "files"}},
+		{name: "tag and glob", args: []string{"--tag", "prod", "db-*"}, want: []string{"db-main"}},
+		{name: "group", args: []string{"--group", "databases", "files"}, want: []string{"db-main", "db-test", "files"}},
+		{name: "unknown name", args: []string{"files", "fils"}, wantErr: "unknown dump fils"},
+		{name: "unknown name with tag", args: []string{"--tag", "test", "files"}, wantErr: "no dumps match selection"},
+		{name: "no glob match", args: []string{"web-*"}, wantErr: "no dumps match selection"},
+		{name: "unknown tag", args: []string{"--tag", "dev"}, wantErr: "no dumps match selection"},
+		{name: "unknown group", args: []string{"--group", "web"}, wantErr: "unknown group web"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel := selection{}
+			flags := newCommandFlags("run", "[dump name patterns...]")
+			sel.register(flags)
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			dumps, err := sel.dumps(config, flags.Args())
+			if len(tt.wantErr) != 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("dumps() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, dump := range dumps {
+				names = append(names, dump.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("dumps() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		command string
+		rest    []string
+	}{
+		{name: "no arguments", command: "run"},
+		{name: "command", args: []string{"status", "db"}, command: "status", rest: []string{"db"}},
+		{name: "explicit run", args: []string{"run", "list"}, command: "run", rest: []string{"list"}},
+		{name: "bare dump names", args: []string{"db", "files"}, command: "run", rest: []string{"db", "files"}},
+		{name: "run flags", args: []string{"--force", "db"}, command: "run", rest: []string{"--force", "db"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, rest := findCommand(tt.args)
+			if cmd.name != tt.command || !reflect.DeepEqual(rest, tt.rest) {
+				t.Errorf("findCommand() = %s %v, want %s %v", cmd.name, rest, tt.command, tt.rest)
+			}
+		})
+	}
+}
+
+func TestCommands_reservedNames(t *testing.T) {
+	//dumps named like commands would not be run by bare name
+	var names []string
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+	if !reflect.DeepEqual(names, configuration.ReservedNames) {
+		t.Errorf("commands %v, want reserved dump names %v", names, configuration.ReservedNames)
+	}
+}
@@ -0,0 +1,287 @@
+package configuration
+
+import (
+	"box/dumper"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// dumpLayout is a dump or a template, which may extend a template
+type dumpLayout struct {
+	dumper.Configuration `yaml:",inline"`
+
+	//name of template to inherit values from
+	Extends string `yaml:"extends"`
+}
+
+// fileLayout describes keys allowed in the main configuration file
+type fileLayout struct {
+	Configuration `yaml:",inline"`
+
+	Dumps []dumpLayout `yaml:"dumps"`
+
+	//glob patterns of files with more dumps, templates and defaults, relative to the configuration file
+	Include []string `yaml:"include"`
+
+	//named dump templates, dumps use them with extends
+	Templates map[string]dumpLayout `yaml:"templates"`
+
+	//values applied to all dumps of type
+	Defaults map[dumper.Type]dumpLayout `yaml:"defaults"`
+}
+
+// includedLayout describes keys allowed in included files
+type includedLayout struct {
+	Dumps     []dumpLayout               `yaml:"dumps"`
+	Templates map[string]dumpLayout      `yaml:"templates"`
+	Defaults  map[dumper.Type]dumpLayout `yaml:"defaults"`
+}
+
+// namedNode is a template or defaults node with the file it is defined in
+type namedNode struct {
+	fileName string
+	node     *yaml.Node
+}
+
+// sources collects dumps, templates and defaults of the main and included files
+type sources struct {
+	dumps     []*yaml.Node
+	dumpFiles []string
+	templates map[string]namedNode
+	defaults  map[string]namedNode
+	included  []namedNode
+
+	//files of nodes which are not defined in the main configuration file
+	files map[*yaml.Node]string
+}
+
+func newSources() *sources {
+	return &sources{
+		templates: make(map[string]namedNode),
+		defaults:  make(map[string]namedNode),
+		files:     make(map[*yaml.Node]string),
+	}
+}
+
+func readNode(fileName string) (*yaml.Node, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read configuration file: %s", err)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, fmt.Errorf("unable to parse configuration file %s: %s", fileName, err)
+	}
+	return &root, nil
+}
+
+// collect adds dumps, templates and defaults of file root node
+func (s *sources) collect(fileName string, root *yaml.Node) []error {
+	var errs []error
+
+	if dumps := findNode(root, "dumps"); dumps != nil && dumps.Kind == yaml.SequenceNode {
+		for _, dump := range dumps.Content {
+			s.dumps = append(s.dumps, dump)
+			s.dumpFiles = append(s.dumpFiles, fileName)
+		}
+	}
+
+	for _, section := range []struct {
+		key   string
+		nodes map[string]namedNode
+	}{
+		{"templates", s.templates},
+		{"defaults", s.defaults},
+	} {
+		node := findNode(root, section.key)
+		if node == nil || node.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name := node.Content[i].Value
+			if previous, ok := section.nodes[name]; ok {
+				errs = append(errs, &Error{fileName, node.Content[i].Line,
+					fmt.Sprintf("%s.%s already defined in %s:%d", section.key, name, previous.fileName, previous.node.Line)})
+				continue
+			}
+			section.nodes[name] = namedNode{fileName, node.Content[i+1]}
+		}
+	}
+
+	return errs
+}
+
+// includes reads files matching include patterns of the main configuration file
+func (s *sources) includes(fileName string, root *yaml.Node) []error {
+	node := findNode(root, "include")
+	if node == nil {
+		return nil
+	}
+
+	var patterns []*yaml.Node
+	switch node.Kind {
+	case yaml.ScalarNode:
+		patterns = []*yaml.Node{node}
+	case yaml.SequenceNode:
+		patterns = node.Content
+	default:
+		return []error{&Error{fileName, node.Line, "include must be a pattern or list of patterns"}}
+	}
+
+	var errs []error
+	directory := filepath.Dir(fileName)
+
+	for _, pattern := range patterns {
+		glob := pattern.Value
+		if !filepath.IsAbs(glob) {
+			glob = filepath.Join(directory, glob)
+		}
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			errs = append(errs, &Error{fileName, pattern.Line, fmt.Sprintf("invalid include pattern %s: %s", pattern.Value, err)})
+			continue
+		}
+		sort.Strings(matches)
+
+		for _, match := range matches {
+			included, err := readNode(match)
+			if err != nil {
+				errs = append(errs, &Error{fileName, pattern.Line, err.Error()})
+				continue
+			}
+			s.included = append(s.included, namedNode{match, included})
+			s.record(match, included)
+			errs = append(errs, checkKnownFields(match, included, reflect.TypeOf(includedLayout{}), "")...)
+			errs = append(errs, s.collect(match, included)...)
+		}
+	}
+
+	return errs
+}
+
+// record remembers file of node and all its children
+func (s *sources) record(fileName string, node *yaml.Node) {
+	s.files[node] = fileName
+	for _, child := range node.Content {
+		s.record(fileName, child)
+	}
+}
+
+// expand applies type defaults and templates to dump node, dump values override
+func (s *sources) expand(fileName string, dump *yaml.Node) (*yaml.Node, error) {
+	result := dump
+	seen := make(map[string]bool)
+
+	for {
+		extends := findNode(result, "extends")
+		if extends == nil {
+			break
+		}
+		template, ok := s.templates[extends.Value]
+		if !ok {
+			return nil, &Error{fileName, extends.Line, fmt.Sprintf("unknown template %q", extends.Value)}
+		}
+		if seen[extends.Value] {
+			return nil, &Error{fileName, extends.Line, fmt.Sprintf("template %q extends itself", extends.Value)}
+		}
+		seen[extends.Value] = true
+
+		//extends of template stays in result and is resolved on the next step
+		result = s.merge(template.node, s.without(result, "extends"))
+	}
+
+	if dumpType := findNode(result, "type"); dumpType != nil {
+		if defaults, ok := s.defaults[dumpType.Value]; ok {
+			result = s.merge(s.without(defaults.node, "extends"), result)
+		}
+	}
+
+	return result, nil
+}
+
+// apply replaces dumps of root node with expanded dumps and removes keys used only for expansion
+func (s *sources) apply(root *yaml.Node) []error {
+	var errs []error
+
+	dumps := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for i, dump := range s.dumps {
+		expanded, err := s.expand(s.dumpFiles[i], dump)
+		if err != nil {
+			errs = append(errs, err)
+			expanded = s.without(dump, "extends")
+		}
+		dumps.Content = append(dumps.Content, expanded)
+	}
+
+	mapping := findNode(root)
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return errs
+	}
+	if existing := findNode(mapping, "dumps"); existing != nil {
+		dumps.Line, dumps.Column = existing.Line, existing.Column
+	}
+
+	for _, key := range []string{"include", "templates", "defaults", "dumps"} {
+		*mapping = *s.without(mapping, key)
+	}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "dumps"}, dumps)
+
+	return errs
+}
+
+// merge returns copy of base mapping with override values, nested mappings (like vars) are merged
+func (s *sources) merge(base, override *yaml.Node) *yaml.Node {
+	if base.Kind != yaml.MappingNode || override.Kind != yaml.MappingNode {
+		return override
+	}
+
+	merged := *override
+	merged.Content = append([]*yaml.Node{}, base.Content...)
+
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		key, value := override.Content[i], override.Content[i+1]
+		replaced := false
+		for j := 0; j+1 < len(merged.Content); j += 2 {
+			if merged.Content[j].Value == key.Value {
+				merged.Content[j+1] = s.merge(merged.Content[j+1], value)
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged.Content = append(merged.Content, key, value)
+		}
+	}
+
+	s.copied(override, &merged)
+	return &merged
+}
+
+// without returns copy of mapping without key
+func (s *sources) without(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return node
+	}
+	result := *node
+	result.Content = nil
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			continue
+		}
+		result.Content = append(result.Content, node.Content[i], node.Content[i+1])
+	}
+	s.copied(node, &result)
+	return &result
+}
+
+// copied gives copy of node the file of original node
+func (s *sources) copied(node, copy *yaml.Node) {
+	if fileName, ok := s.files[node]; ok {
+		s.files[copy] = fileName
+	}
+}
@@ -0,0 +1,80 @@
+package configuration
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRead_includeAndTemplates(t *testing.T) {
+	fileName := writeConfiguration(t, `
+global:
+  path: dump
+  tmp-path: tmp
+include: conf.d/*.yml
+defaults:
+  tar:
+    vars:
+      compress: gzip
+      path: /srv
+templates:
+  nightly:
+    daily: true
+    keep-daily: 7d
+  nightly-long:
+    extends: nightly
+    keep-daily: 30d
+dumps:
+  - name: files
+    type: tar
+    extends: nightly-long
+    vars:
+      path: /home
+`)
+
+	directory := filepath.Join(filepath.Dir(fileName), "conf.d")
+	if err := os.Mkdir(directory, 0755); err != nil {
+		t.Fatal(err)
+	}
+	included := `
+dumps:
+  - name: etc
+    type: tar
+    extends: nightly
+    vars:
+      compress: xz
+  - name: broken
+    type: tar
+    extends: missing
+`
+	if err := os.WriteFile(filepath.Join(directory, "a.yml"), []byte(included), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Read(fileName)
+	if err == nil || !strings.Contains(err.Error(), "a.yml:10: unknown template \"missing\"") {
+		t.Fatalf("expected unknown template error in included file, got %v", err)
+	}
+
+	included = strings.Replace(included, "missing", "nightly", 1)
+	if err := os.WriteFile(filepath.Join(directory, "a.yml"), []byte(included), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := Read(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Dumps) != 3 {
+		t.Fatalf("expected 3 dumps, got %d", len(config.Dumps))
+	}
+
+	files, etc := config.Dumps[0], config.Dumps[1]
+	if !files.Daily || files.KeepDaily.String() != "30d" || files.Vars["path"] != "/home" || files.Vars["compress"] != "gzip" {
+		t.Errorf("unexpected files dump: %+v", files)
+	}
+	if !etc.Daily || etc.KeepDaily.String() != "7d" || etc.Vars["path"] != "/srv" || etc.Vars["compress"] != "xz" {
+		t.Errorf("unexpected etc dump: %+v", etc)
+	}
+}
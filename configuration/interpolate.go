@@ -0,0 +1,87 @@
+package configuration
+
+import (
+	"box/dumper"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ${NAME} is replaced by environment variable, ${file:/path} by file content, $${ is literal ${
+var referenceRegexp = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+
+// interpolate resolves references in all scalar values of node tree, values of ${file:} references
+// and of secret keys like password are collected as secrets
+func (config *Configuration) interpolate(fileName string, node *yaml.Node, secret bool) []error {
+	var errs []error
+
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			errs = append(errs, config.interpolate(fileName, child, secret)...)
+		}
+	case yaml.MappingNode:
+		//keys are not interpolated
+		for i := 1; i < len(node.Content); i += 2 {
+			errs = append(errs, config.interpolate(fileName, node.Content[i], secret || dumper.IsSecretKey(node.Content[i-1].Value))...)
+		}
+	case yaml.ScalarNode:
+		if strings.Contains(node.Value, "${") {
+			node.Value = referenceRegexp.ReplaceAllStringFunc(node.Value, func(reference string) string {
+				if strings.HasPrefix(reference, "$$") {
+					return reference[1:]
+				}
+				resolved, err := resolveReference(reference[2 : len(reference)-1])
+				if err != nil {
+					errs = append(errs, &Error{fileName, node.Line, err.Error()})
+					return reference
+				}
+				//whole value of secret key is collected below
+				if !secret && strings.HasPrefix(reference, "${file:") && len(resolved) != 0 {
+					config.secrets = append(config.secrets, resolved)
+				}
+				return resolved
+			})
+
+			//plain scalars get their type from resolved value, so numbers and booleans can be referenced
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+
+		if secret && len(node.Value) != 0 {
+			config.secrets = append(config.secrets, node.Value)
+		}
+	}
+
+	return errs
+}
+
+func resolveReference(reference string) (string, error) {
+	if fileName, ok := strings.CutPrefix(reference, "file:"); ok {
+		content, err := os.ReadFile(fileName)
+		if err != nil {
+			return "", fmt.Errorf("unable to resolve ${file:%s}: %s", fileName, err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+
+	if len(reference) == 0 {
+		return "", fmt.Errorf("empty reference ${}")
+	}
+
+	value, ok := os.LookupEnv(reference)
+	if !ok {
+		return "", fmt.Errorf("unable to resolve ${%s}: environment variable not set", reference)
+	}
+
+	return value, nil
+}
+
+// Secrets returns values of secret references and keys, they must not appear in logs
+func (config *Configuration) Secrets() []string {
+	return config.secrets
+}
@@ -0,0 +1,77 @@
+package configuration
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRead_interpolation(t *testing.T) {
+	secretFileName := filepath.Join(t.TempDir(), "pg")
+	if err := os.WriteFile(secretFileName, []byte("hunter2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BOX_TEST_HOST", "db.example.com")
+	t.Setenv("BOX_TEST_DAYS", "7")
+	t.Setenv("BOX_TEST_TOKEN", "x1")
+
+	config, err := Read(writeConfiguration(t, `
+global:
+  path: dump
+  tmp-path: tmp
+dumps:
+  - type: postgres
+    name: pg
+    vars:
+      host: ${BOX_TEST_HOST}
+      password: "${file:`+secretFileName+`}"
+      dbname: "$${literal}"
+      options: "--sslkey=${file:`+secretFileName+`}"
+      api-key: ${BOX_TEST_TOKEN}
+      secret-token: ab
+    daily: true
+    days: ${BOX_TEST_DAYS}
+`))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	dump := config.Dumps[0]
+	if dump.Vars["host"] != "db.example.com" {
+		t.Errorf("host = %s", dump.Vars["host"])
+	}
+	if dump.Vars["password"] != "hunter2" {
+		t.Errorf("password = %s", dump.Vars["password"])
+	}
+	if dump.Vars["dbname"] != "${literal}" {
+		t.Errorf("dbname = %s", dump.Vars["dbname"])
+	}
+	if dump.Days != 7 {
+		t.Errorf("days = %d", dump.Days)
+	}
+
+	//environment values like host are not secrets, short secrets are masked too
+	secrets := strings.Join(config.Secrets(), ",")
+	if secrets != "hunter2,hunter2,x1,ab" {
+		t.Errorf("Secrets() = %s", secrets)
+	}
+}
+
+func TestRead_interpolationUnresolved(t *testing.T) {
+	os.Unsetenv("BOX_TEST_UNSET")
+
+	_, err := Read(writeConfiguration(t, `
+global:
+  path: dump
+  tmp-path: tmp
+dumps:
+  - type: tar
+    name: files
+    vars:
+      path: ${BOX_TEST_UNSET}
+`))
+	if err == nil || !strings.Contains(err.Error(), ":9: unable to resolve ${BOX_TEST_UNSET}") {
+		t.Errorf("Read() error = %v", err)
+	}
+}
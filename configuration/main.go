@@ -3,16 +3,61 @@ package configuration
 import (
 	"box/dumper"
 	"box/notifier"
+	"errors"
 	"fmt"
 	"gopkg.in/yaml.v3"
 	"io"
 	"os"
+	"path"
+	"reflect"
 )
 
 type Configuration struct {
 	Global       dumper.GlobalConfiguration
 	Dumps        []dumper.Configuration
 	Notification notifier.Configuration
+
+	//notification channels besides legacy mattermost notification
+	Notifications []notifier.ChannelConfiguration `yaml:"notifications"`
+
+	//pings when run starts and finishes
+	Heartbeat dumper.Heartbeat `yaml:"heartbeat"`
+
+	//timeout, retries, proxy and outbox of notifications and heartbeat pings
+	NotificationDelivery notifier.DeliveryConfiguration `yaml:"notification-delivery"`
+
+	//which notifications are sent, dumps can replace it with their own policy
+	NotificationPolicy dumper.NotificationPolicy `yaml:"notification-policy"`
+
+	//web dashboard of serve command
+	Server ServerConfiguration `yaml:"server"`
+
+	//named lists of dump name patterns, selected with --group
+	Groups map[string][]string `yaml:"groups"`
+
+	fileName string
+	root     *yaml.Node
+	secrets  []string
+
+	//files of nodes defined in included files
+	nodeFiles map[*yaml.Node]string
+}
+
+// ServerConfiguration is used by serve command
+type ServerConfiguration struct {
+	//address to listen, 127.0.0.1:8080 by default
+	Listen string `yaml:"listen"`
+
+	//basic auth credentials, dashboard is read-only without them: dumps can not be run or downloaded, logs are not shown
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+
+	//certificate and key files to serve https
+	TlsCert string `yaml:"tls-cert"`
+	TlsKey  string `yaml:"tls-key"`
+
+	//tokens of api clients, sent as Authorization: Bearer <token>, api is disabled without them
+	ApiTokens []string `yaml:"api-tokens"`
 }
 
 func Read(fileName string) (*Configuration, error) {
@@ -48,9 +93,98 @@ func Read(fileName string) (*Configuration, error) {
 		return nil, fmt.Errorf("unable to read configuration file: %s", err)
 	}
 
-	if err := yaml.Unmarshal(content, &config); err != nil {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
 		return nil, fmt.Errorf("unable to parse configuration file: %s", err)
 	}
 
+	config.fileName = fileName
+	config.root = &root
+
+	errs := checkKnownFields(fileName, &root, reflect.TypeOf(fileLayout{}), "")
+
+	src := newSources()
+	errs = append(errs, src.collect(fileName, &root)...)
+	errs = append(errs, src.includes(fileName, &root)...)
+
+	interpolationErrs := config.interpolate(fileName, &root, false)
+	for _, included := range src.included {
+		interpolationErrs = append(interpolationErrs, config.interpolate(included.fileName, included.node, false)...)
+	}
+	if len(interpolationErrs) > 0 {
+		return nil, errors.Join(append(errs, interpolationErrs...)...)
+	}
+
+	if expandErrs := src.apply(&root); len(expandErrs) > 0 {
+		return nil, errors.Join(append(errs, expandErrs...)...)
+	}
+	config.nodeFiles = src.files
+
+	if err := root.Decode(&config); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %s", fileName, err))
+		return nil, errors.Join(errs...)
+	}
+
+	errs = append(errs, config.validate()...)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	config.Global.Secrets = config.secrets
+	config.Global.Dumps = config.Dumps
+
 	return &config, nil
 }
+
+// Select returns dumps with name matching any of glob patterns or patterns of groups and having any of tags,
+// empty patterns, groups or tags match all dumps
+func (config *Configuration) Select(patterns, tags, groups []string) ([]dumper.Configuration, error) {
+	patterns = append([]string{}, patterns...)
+	for _, group := range groups {
+		groupPatterns, ok := config.Groups[group]
+		if !ok {
+			return nil, fmt.Errorf("unknown group %s", group)
+		}
+		patterns = append(patterns, groupPatterns...)
+	}
+
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid dump name pattern %s: %s", pattern, err)
+		}
+	}
+
+	var selected []dumper.Configuration
+
+	for _, dump := range config.Dumps {
+		if len(patterns) > 0 && !matchesAny(dump.Name, patterns) {
+			continue
+		}
+		if len(tags) > 0 && !hasAnyTag(dump.Tags, tags) {
+			continue
+		}
+		selected = append(selected, dump)
+	}
+
+	return selected, nil
+}
+
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyTag(dumpTags, tags []string) bool {
+	for _, dumpTag := range dumpTags {
+		for _, tag := range tags {
+			if dumpTag == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package configuration
+
+import (
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	fileName := writeConfiguration(t, `
+global:
+  path: dump
+  tmp-path: tmp
+groups:
+  nightly: ["pg_*", "files"]
+dumps:
+  - {name: pg_one, type: tar, tags: [critical], vars: {path: /srv}, latest: true}
+  - {name: pg_two, type: tar, vars: {path: /srv}, latest: true}
+  - {name: files, type: tar, tags: [critical], vars: {path: /srv}, latest: true}
+  - {name: mail, type: tar, vars: {path: /srv}, latest: true}
+`)
+
+	config, err := Read(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		tags     []string
+		groups   []string
+		expected []string
+	}{
+		{"all", nil, nil, nil, []string{"pg_one", "pg_two", "files", "mail"}},
+		{"pattern", []string{"pg_*"}, nil, nil, []string{"pg_one", "pg_two"}},
+		{"tag", nil, []string{"critical"}, nil, []string{"pg_one", "files"}},
+		{"group", nil, nil, []string{"nightly"}, []string{"pg_one", "pg_two", "files"}},
+		{"group or pattern", []string{"mail"}, nil, []string{"nightly"}, []string{"pg_one", "pg_two", "files", "mail"}},
+		{"group and tag", nil, []string{"critical"}, []string{"nightly"}, []string{"pg_one", "files"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dumps, err := config.Select(test.patterns, test.tags, test.groups)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, dump := range dumps {
+				names = append(names, dump.Name)
+			}
+			if len(names) != len(test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, names)
+			}
+			for i := range names {
+				if names[i] != test.expected[i] {
+					t.Fatalf("expected %v, got %v", test.expected, names)
+				}
+			}
+		})
+	}
+
+	if _, err := config.Select(nil, nil, []string{"missing"}); err == nil {
+		t.Error("expected error for unknown group")
+	}
+}
@@ -0,0 +1,568 @@
+package configuration
+
+import (
+	"box/dumper"
+	"box/notifier"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Error is a configuration problem at line of configuration file
+type Error struct {
+	FileName string
+	Line     int
+	Message  string
+}
+
+func (e *Error) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.FileName, e.Line, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.FileName, e.Message)
+}
+
+// minApiTokenLength makes api tokens hard to guess
+const minApiTokenLength = 16
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// checkKnownFields reports mapping keys that do not match yaml tags of structure fields
+func checkKnownFields(fileName string, node *yaml.Node, t reflect.Type, path string) []error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		var errs []error
+		for _, child := range node.Content {
+			errs = append(errs, checkKnownFields(fileName, child, t, path)...)
+		}
+		return errs
+	case yaml.AliasNode:
+		return checkKnownFields(fileName, node.Alias, t, path)
+	}
+
+	//custom types parse their own values
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return nil
+	}
+
+	var errs []error
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		fields := make(map[string]reflect.Type)
+		structFields(t, fields)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				continue
+			}
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				errs = append(errs, &Error{fileName, key.Line, fmt.Sprintf("unknown field %s", joinPath(path, key.Value))})
+				continue
+			}
+			errs = append(errs, checkKnownFields(fileName, value, fieldType, joinPath(path, key.Value))...)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			errs = append(errs, checkKnownFields(fileName, node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value))...)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i, item := range node.Content {
+			errs = append(errs, checkKnownFields(fileName, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+
+	return errs
+}
+
+// structFields adds yaml keys of structure fields, fields of inlined structures are included
+func structFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		if len(tag) > 1 && tag[1] == "inline" && field.Type.Kind() == reflect.Struct {
+			structFields(field.Type, fields)
+			continue
+		}
+		if len(name) == 0 {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+}
+
+func joinPath(path, key string) string {
+	if len(path) == 0 {
+		return key
+	}
+	return path + "." + key
+}
+
+// findNode returns value node at path of mapping keys and sequence indexes
+func findNode(node *yaml.Node, path ...interface{}) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if len(path) == 0 {
+		return node
+	}
+
+	switch key := path[0].(type) {
+	case string:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				return findNode(node.Content[i+1], path[1:]...)
+			}
+		}
+	case int:
+		if node.Kind != yaml.SequenceNode || key >= len(node.Content) {
+			return nil
+		}
+		return findNode(node.Content[key], path[1:]...)
+	}
+
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// errorAt returns error at line of node found by path, or the closest existing parent
+func (config *Configuration) errorAt(message string, path ...interface{}) error {
+	line := 0
+	fileName := config.fileName
+	for i := len(path); i >= 0; i-- {
+		if node := findNode(config.root, path[:i]...); node != nil {
+			line = node.Line
+			if included, ok := config.nodeFiles[node]; ok {
+				fileName = included
+			}
+			break
+		}
+	}
+	return &Error{fileName, line, message}
+}
+
+// ReservedNames are box commands, `box <name>` would run the command instead of the dump
+var ReservedNames = []string{"run", "list", "status", "verify", "prune", "restore", "history", "serve", "config"}
+
+// validate checks values which are syntactically correct, but would fail at run time
+func (config *Configuration) validate() []error {
+	var errs []error
+
+	errs = append(errs, config.validateStorage(config.Global.Storage, "global", "storage")...)
+
+	names := make(map[string]int)
+	rootPaths := make(map[string]int)
+	tmpFileNames := make(map[string]int)
+
+	for i, dump := range config.Dumps {
+		title := fmt.Sprintf("dumps[%d]", i)
+		if len(dump.Name) != 0 {
+			title = fmt.Sprintf("dump %s", dump.Name)
+		}
+
+		if !isKnownType(dump.Type) {
+			errs = append(errs, config.errorAt(fmt.Sprintf("%s: unknown type %q, expected one of: %s", title, dump.Type, typeNames()), "dumps", i, "type"))
+		}
+
+		duplicate := false
+
+		if len(dump.Name) == 0 {
+			errs = append(errs, config.errorAt(fmt.Sprintf("%s: name required", title), "dumps", i))
+		} else if strings.ContainsAny(dump.Name, "/\\") || dump.Name == "." || dump.Name == ".." {
+			errs = append(errs, config.errorAt(fmt.Sprintf("%s: name must not contain path separators", title), "dumps", i, "name"))
+		} else if isReservedName(dump.Name) {
+			errs = append(errs, config.errorAt(fmt.Sprintf("%s: name is reserved for command %s", title, dump.Name), "dumps", i, "name"))
+		} else if first, ok := names[dump.Name]; ok {
+			errs = append(errs, config.errorAt(fmt.Sprintf("%s: duplicate name, already used by dumps[%d]", title, first), "dumps", i, "name"))
+			duplicate = true
+		} else {
+			names[dump.Name] = i
+		}
+
+		//paths of dumps with duplicate names collide anyway
+		if len(dump.Path) == 0 && len(config.Global.Path) == 0 {
+			errs = append(errs, config.errorAt(fmt.Sprintf("%s: path not defined, neither global nor dump path is set", title), "dumps", i))
+		} else if !duplicate {
+			rootPath := filepath.Clean(dumper.RootPath(config.Global, dump))
+			if first, ok := rootPaths[rootPath]; ok {
+				errs = append(errs, config.errorAt(fmt.Sprintf("%s: path %s is already used by dumps[%d]", title, rootPath, first), "dumps", i, "path"))
+			} else {
+				rootPaths[rootPath] = i
+			}
+		}
+
+		if len(dump.TmpPath) == 0 && len(config.Global.TmpPath) == 0 {
+			errs = append(errs, config.errorAt(fmt.Sprintf("%s: tmp path not defined, neither global nor dump tmp-path is set", title), "dumps", i))
+		} else if !duplicate {
+			tmpFileName := filepath.Clean(dumper.TmpDumpFileName(config.Global, dump))
+			if first, ok := tmpFileNames[tmpFileName]; ok {
+				errs = append(errs, config.errorAt(fmt.Sprintf("%s: tmp file %s is already used by dumps[%d]", title, tmpFileName, first), "dumps", i, "tmp-path"))
+			} else {
+				tmpFileNames[tmpFileName] = i
+			}
+		}
+
+		errs = append(errs, config.validateStorage(dump.Storage, "dumps", i, "storage")...)
+
+		for _, period := range []struct {
+			enabled   bool
+			countName string
+			count     int
+			keepName  string
+			keep      dumper.Retention
+		}{
+			{dump.Hourly, "hours", dump.Hours, "keep-hourly", dump.KeepHourly},
+			{dump.Daily, "days", dump.Days, "keep-daily", dump.KeepDaily},
+			{dump.Weekly, "weeks", dump.Weeks, "keep-weekly", dump.KeepWeekly},
+			{dump.Monthly, "months", dump.Months, "keep-monthly", dump.KeepMonthly},
+			{dump.Yearly, "years", dump.Years, "keep-yearly", dump.KeepYearly},
+		} {
+			if !period.enabled || !period.keep.IsZero() {
+				continue
+			}
+			if period.count < -1 {
+				errs = append(errs, config.errorAt(fmt.Sprintf("%s: %s must be positive or -1 to keep forever", title, period.countName), "dumps", i, period.countName))
+			} else if period.count == 0 {
+				errs = append(errs, config.errorAt(fmt.Sprintf("%s: %s or %s required, 0 would delete every dump", title, period.countName, period.keepName), "dumps", i))
+			}
+		}
+
+		errs = append(errs, config.validateHeartbeat(title, dump.Heartbeat, "dumps", i, "heartbeat")...)
+
+		if dump.NotificationPolicy != nil {
+			errs = append(errs, config.validateNotificationPolicy(title, *dump.NotificationPolicy, false, "dumps", i, "notification-policy")...)
+		}
+
+		if dump.Retries < 0 {
+			errs = append(errs, config.errorAt(fmt.Sprintf("%s: retries must not be negative", title), "dumps", i, "retries"))
+		}
+
+		for _, name := range dumper.RequiredVars(dump.Type) {
+			if len(dump.Vars[name]) == 0 {
+				errs = append(errs, config.errorAt(fmt.Sprintf("%s: vars.%s required for %s", title, name, dump.Type), "dumps", i, "vars"))
+			}
+		}
+
+		if dump.Type == dumper.TypeTar {
+			switch dump.Vars["compress"] {
+			case "", "none", "bzip2", "gzip", "lzma", "xz":
+			default:
+				errs = append(errs, config.errorAt(fmt.Sprintf("%s: unknown compress %q, expected one of: none, bzip2, gzip, lzma, xz", title, dump.Vars["compress"]), "dumps", i, "vars", "compress"))
+			}
+		}
+	}
+
+	errs = append(errs, config.validateGroups()...)
+	errs = append(errs, config.validateNotifications()...)
+	errs = append(errs, config.validateNotificationPolicy("notification-policy", config.NotificationPolicy, true, "notification-policy")...)
+	errs = append(errs, config.validateHeartbeat("heartbeat", config.Heartbeat, "heartbeat")...)
+
+	if (len(config.Server.Username) == 0) != (len(config.Server.Password) == 0) {
+		errs = append(errs, config.errorAt("server: username and password are set together", "server"))
+	}
+	if (len(config.Server.TlsCert) == 0) != (len(config.Server.TlsKey) == 0) {
+		errs = append(errs, config.errorAt("server: tls-cert and tls-key are set together", "server"))
+	}
+	for i, token := range config.Server.ApiTokens {
+		if len(token) < minApiTokenLength {
+			errs = append(errs, config.errorAt(fmt.Sprintf("server.api-tokens[%d]: token must have at least %d characters", i, minApiTokenLength), "server", "api-tokens", i))
+		}
+	}
+
+	return errs
+}
+
+func (config *Configuration) validateHeartbeat(title string, heartbeat dumper.Heartbeat, path ...interface{}) []error {
+	var errs []error
+
+	for _, field := range []struct {
+		key   string
+		value string
+	}{
+		{"url", heartbeat.Url},
+		{"start-url", heartbeat.StartUrl},
+		{"success-url", heartbeat.SuccessUrl},
+		{"failure-url", heartbeat.FailureUrl},
+	} {
+		if len(field.value) == 0 {
+			continue
+		}
+		if u, err := url.ParseRequestURI(field.value); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			errs = append(errs, config.errorAt(fmt.Sprintf("%s: %s must be http or https url", title, field.key), append(path, field.key)...))
+		}
+	}
+
+	return errs
+}
+
+func (config *Configuration) validateNotificationPolicy(title string, policy dumper.NotificationPolicy, global bool, path ...interface{}) []error {
+	var errs []error
+
+	switch policy.Send {
+	case "", dumper.NotifyAll, dumper.NotifyFailures, dumper.NotifyChanges:
+	default:
+		errs = append(errs, config.errorAt(fmt.Sprintf("%s: unknown send %q, expected one of: all, failures, changes", title, policy.Send), append(path, "send")...))
+	}
+
+	if policy.RemindAfter < 0 {
+		errs = append(errs, config.errorAt(fmt.Sprintf("%s: remind-after must not be negative", title), append(path, "remind-after")...))
+	}
+
+	if len(policy.DailySummary) != 0 {
+		if !global {
+			errs = append(errs, config.errorAt(fmt.Sprintf("%s: daily-summary is set in global notification-policy only", title), append(path, "daily-summary")...))
+		} else if _, err := time.Parse("15:04", policy.DailySummary); err != nil {
+			errs = append(errs, config.errorAt(fmt.Sprintf("%s: daily-summary must be time of day like 08:00", title), append(path, "daily-summary")...))
+		}
+	}
+
+	return errs
+}
+
+func (config *Configuration) validateNotifications() []error {
+	var errs []error
+
+	if config.Notification.Enabled && len(config.Notification.Url) == 0 {
+		errs = append(errs, config.errorAt("notification: url required", "notification"))
+	}
+	errs = append(errs, config.validateTemplates("notification", config.Notification.Templates, "notification")...)
+
+	if _, err := notifier.NewDelivery(config.NotificationDelivery, ""); err != nil {
+		errs = append(errs, config.errorAt(fmt.Sprintf("notification-delivery: %s", err), "notification-delivery"))
+	}
+	if config.NotificationDelivery.Retries < 0 {
+		errs = append(errs, config.errorAt("notification-delivery: retries must not be negative", "notification-delivery", "retries"))
+	}
+
+	for i, channel := range config.Notifications {
+		title := fmt.Sprintf("notifications[%d]", i)
+
+		if _, err := notifier.NewChannel(channel); err != nil {
+			if !isKnownChannelType(channel.Type) {
+				errs = append(errs, config.errorAt(fmt.Sprintf("%s: %s, expected one of: %s", title, err, strings.Join(notifier.Types, ", ")), "notifications", i, "type"))
+			} else {
+				errs = append(errs, config.errorAt(fmt.Sprintf("%s: %s", title, err), "notifications", i))
+			}
+			continue
+		}
+
+		if _, err := notifier.ParseSeverity(channel.MinSeverity); err != nil {
+			errs = append(errs, config.errorAt(fmt.Sprintf("%s: %s", title, err), "notifications", i, "min-severity"))
+		}
+		errs = append(errs, config.validateTemplates(title, channel.Templates, "notifications", i)...)
+
+		if !channel.Enabled {
+			continue
+		}
+
+		values := map[string]string{
+			"url":     channel.Url,
+			"token":   channel.Token,
+			"chat-id": channel.ChatId,
+			"room-id": channel.RoomId,
+			"host":    channel.Host,
+			"from":    channel.From,
+			"to":      strings.Join(channel.To, ","),
+		}
+		for _, field := range notifier.RequiredFields(channel.Type) {
+			if len(values[field]) == 0 {
+				errs = append(errs, config.errorAt(fmt.Sprintf("%s: %s required for %s", title, field, channel.Type), "notifications", i))
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateTemplates parses message templates of channel one by one to report every broken template
+func (config *Configuration) validateTemplates(title string, templates map[string]string, path ...interface{}) []error {
+	var errs []error
+
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := notifier.ParseTemplates(map[string]string{name: templates[name]}); err != nil {
+			errs = append(errs, config.errorAt(fmt.Sprintf("%s: %s", title, err), append(path, "templates", name)...))
+		}
+	}
+
+	return errs
+}
+
+// validateGroups checks group patterns, a group matching no dumps is most likely a typo
+func (config *Configuration) validateGroups() []error {
+	var errs []error
+
+	groups := make([]string, 0, len(config.Groups))
+	for group := range config.Groups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		patterns := config.Groups[group]
+		if len(patterns) == 0 {
+			errs = append(errs, config.errorAt(fmt.Sprintf("group %s is empty", group), "groups", group))
+			continue
+		}
+		valid := true
+		for i, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				errs = append(errs, config.errorAt(fmt.Sprintf("group %s: invalid pattern %s: %s", group, pattern, err), "groups", group, i))
+				valid = false
+			}
+		}
+		if !valid {
+			continue
+		}
+		if dumps, _ := config.Select(nil, nil, []string{group}); len(dumps) == 0 {
+			errs = append(errs, config.errorAt(fmt.Sprintf("group %s matches no dumps", group), "groups", group))
+		}
+	}
+
+	return errs
+}
+
+func (config *Configuration) validateStorage(storage dumper.StorageMode, path ...interface{}) []error {
+	switch storage {
+	case "", dumper.StorageCopy, dumper.StorageHardlink, dumper.StorageReflink:
+		return nil
+	default:
+		return []error{config.errorAt(fmt.Sprintf("unknown storage %q, expected one of: copy, hardlink, reflink", storage), path...)}
+	}
+}
+
+// CheckExecutables checks executables used by configured dumps are available
+func (config *Configuration) CheckExecutables() []error {
+	type executable struct {
+		name string
+		key  string
+	}
+
+	executables := []executable{{config.Global.ShExecutable, "sh-executable"}}
+	for _, dump := range config.Dumps {
+		for _, name := range dumper.Executables(config.Global, dump.Type) {
+			executables = append(executables, executable{name, executableKey(config.Global, name)})
+		}
+	}
+
+	var errs []error
+	checked := make(map[string]bool)
+
+	for _, e := range executables {
+		if checked[e.name] {
+			continue
+		}
+		checked[e.name] = true
+
+		if len(e.name) == 0 {
+			errs = append(errs, config.errorAt(fmt.Sprintf("%s not defined", e.key), "global"))
+			continue
+		}
+		if _, err := exec.LookPath(e.name); err != nil {
+			message := fmt.Sprintf("executable %s not found: %s", e.name, err)
+			if len(e.key) != 0 {
+				errs = append(errs, config.errorAt(message, "global", e.key))
+			} else {
+				errs = append(errs, config.errorAt(message))
+			}
+		}
+	}
+
+	return errs
+}
+
+// executableKey returns global configuration key of executable, empty for fixed executables
+func executableKey(global dumper.GlobalConfiguration, name string) string {
+	switch name {
+	case global.PgdumpExecutable:
+		return "pgdump-executable"
+	case global.MysqldumpExecutable:
+		return "mysqldump-executable"
+	case global.Mongodump5Executable:
+		return "mongodump-5-executable"
+	case global.Mongodump4Executable:
+		return "mongodump-4-executable"
+	case global.GbakExecutable:
+		return "gbak-executable"
+	case global.TarExecutable:
+		return "tar-executable"
+	default:
+		return ""
+	}
+}
+
+func isKnownType(dumpType dumper.Type) bool {
+	for _, t := range dumper.Types {
+		if t == dumpType {
+			return true
+		}
+	}
+	return false
+}
+
+func isKnownChannelType(channelType string) bool {
+	for _, t := range notifier.Types {
+		if t == channelType {
+			return true
+		}
+	}
+	return false
+}
+
+func typeNames() string {
+	var names []string
+	for _, t := range dumper.Types {
+		names = append(names, string(t))
+	}
+	return strings.Join(names, ", ")
+}
+
+func isReservedName(name string) bool {
+	for _, reserved := range ReservedNames {
+		if name == reserved {
+			return true
+		}
+	}
+	return false
+}
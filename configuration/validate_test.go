@@ -0,0 +1,159 @@
+package configuration
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfiguration(t *testing.T, content string) string {
+	fileName := filepath.Join(t.TempDir(), "application.yml")
+	if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+func TestRead_validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errors  []string
+	}{
+		{
+			name: "valid",
+			content: `
+global:
+  path: dump
+  tmp-path: tmp
+dumps:
+  - type: tar
+    name: files
+    vars:
+      path: /srv
+    daily: true
+    keep-daily: 14d
+`,
+		}, {
+			name: "unknown field",
+			content: `
+global:
+  path: dump
+  tmp-path: tmp
+dumps:
+  - type: tar
+    name: files
+    dayz: 14
+    vars:
+      path: /srv
+`,
+			errors: []string{":8: unknown field dumps[0].dayz"},
+		}, {
+			name: "unknown type and duplicate name",
+			content: `
+global:
+  path: dump
+  tmp-path: tmp
+dumps:
+  - type: tar
+    name: files
+    vars:
+      path: /srv
+  - type: postgress
+    name: files
+`,
+			errors: []string{
+				`:10: dump files: unknown type "postgress"`,
+				":11: dump files: duplicate name, already used by dumps[0]",
+			},
+		}, {
+			name: "reserved name",
+			content: `
+global:
+  path: dump
+  tmp-path: tmp
+dumps:
+  - type: tar
+    name: status
+    vars:
+      path: /srv
+`,
+			errors: []string{":7: dump status: name is reserved for command status"},
+		}, {
+			name: "retention and required vars",
+			content: `
+global:
+  path: dump
+  tmp-path: tmp
+dumps:
+  - type: mysql
+    name: db
+    daily: true
+    days: -5
+`,
+			errors: []string{
+				":9: dump db: days must be positive or -1 to keep forever",
+				":6: dump db: vars.database required for mysql",
+			},
+		}, {
+			name: "tmp file collision",
+			content: `
+global:
+  path: dump
+  tmp-path: tmp
+dumps:
+  - type: tar
+    name: files
+    vars:
+      path: /srv
+  - type: tar
+    name: other
+    path: dump/files
+    vars:
+      path: /srv
+`,
+			errors: []string{":12: dump other: path dump/files is already used by dumps[0]"},
+		}, {
+			name: "server",
+			content: `
+global:
+  path: dump
+  tmp-path: tmp
+server:
+  username: admin
+  api-tokens:
+    - 0123456789abcdef
+    - short
+dumps:
+  - type: tar
+    name: files
+    vars:
+      path: /srv
+`,
+			errors: []string{
+				":6: server: username and password are set together",
+				":9: server.api-tokens[1]: token must have at least 16 characters",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(writeConfiguration(t, tt.content))
+			if len(tt.errors) == 0 {
+				if err != nil {
+					t.Fatalf("Read() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Read() error = nil, want %v", tt.errors)
+			}
+			for _, want := range tt.errors {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Read() error = %v, want %s", err, want)
+				}
+			}
+		})
+	}
+}
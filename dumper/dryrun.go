@@ -0,0 +1,81 @@
+package dumper
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// dryRun prints what execute would do without running or touching anything
+func (dumper *AbstractDumper) dryRun(commandline string) error {
+	name := dumper.configuration.Name
+	dumpType := dumper.configuration.Type
+
+	dumpNeeded := dumper.isDumpNeeded()
+	dumper.report.Planned = dumpNeeded
+
+	log.Infof("[dry-run] %s (%s) dump needed: %v", name, dumpType, dumpNeeded)
+	log.Infof("[dry-run] %s (%s) command: %s", name, dumpType, dumper.maskSecrets(commandline))
+
+	if dumpNeeded {
+		for _, hook := range dumper.hooks() {
+			if len(hook.command) != 0 {
+				log.Infof("[dry-run] %s (%s) %s hook: %s", name, dumpType, hook.name, dumper.maskSecrets(hook.command))
+			}
+		}
+		for _, period := range dumper.periods() {
+			if !period.enabled {
+				continue
+			}
+			if period.exists() && !period.overwrite {
+				log.Infof("[dry-run] %s (%s) keep existing %s", name, dumpType, period.dumpFileName())
+				continue
+			}
+			log.Infof("[dry-run] %s (%s) write %s (%s)", name, dumpType, period.dumpFileName(), dumper.storageMode())
+		}
+	}
+
+	return dumper.dryRunRetention()
+}
+
+// dryRunRetention prints files retention and quotas would delete
+func (dumper *AbstractDumper) dryRunRetention() error {
+	name := dumper.configuration.Name
+	dumpType := dumper.configuration.Type
+
+	if !dumper.latest.enabled && dumper.latest.exists() {
+		log.Infof("[dry-run] %s (%s) delete %s", name, dumpType, dumper.latest.dumpFileName())
+	}
+
+	for _, period := range dumper.tiers() {
+		if !period.enabled {
+			continue
+		}
+		expired, err := period.expired()
+		if err != nil {
+			return err
+		}
+		for _, fileName := range expired {
+			log.Infof("[dry-run] %s (%s) delete expired %s%c%s (retention %s)",
+				name, dumpType, period.rootPath, os.PathSeparator, fileName, period.retention)
+		}
+	}
+
+	if dumper.configuration.MaxTotalSize > 0 || dumper.globalConfiguration.MaxTotalSize > 0 {
+		log.Infof("[dry-run] %s (%s) max-total-size pruning depends on dump sizes, not simulated", name, dumpType)
+	}
+
+	return nil
+}
+
+// maskSecrets replaces secrets of configuration and secret variable values in commandline
+func (dumper *AbstractDumper) maskSecrets(commandline string) string {
+	secrets := append([]string{}, dumper.globalConfiguration.Secrets...)
+	for key, value := range dumper.configuration.Vars {
+		if IsSecretKey(key) {
+			secrets = append(secrets, value)
+		}
+	}
+
+	return NewSecretReplacer(secrets).Replace(commandline)
+}
@@ -0,0 +1,64 @@
+package dumper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// listFiles returns all files under directory, relative to it
+func listFiles(t *testing.T, directory string) []string {
+	var files []string
+	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(directory, path)
+		files = append(files, filepath.ToSlash(relative))
+		return err
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestAbstractDumper_dryRun(t *testing.T) {
+	global := testGlobalConfiguration(t)
+	global.PgdumpExecutable = fakeExecutable(t)
+	global.DryRun = true
+	global.MaxTotalSize = 150
+
+	//expired daily dumps and latest which is no longer configured
+	expired := time.Now().AddDate(0, 0, -30).Format("2006-01-02")
+	writeDumps(t, global.Path, "pg/latest", "pg/daily/"+expired)
+	before := listFiles(t, filepath.Dir(global.Path))
+
+	dumper, err := NewPostgres(global, Configuration{Name: "pg", Type: TypePostgres, Daily: true, Days: 7, Vars: map[string]string{"dbname": "db"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dumper.Dump(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if report := dumper.Report(); !report.Planned || report.Dumped || len(report.Files) != 0 || len(report.Rotated) != 0 || len(report.Pruned) != 0 {
+		t.Errorf("Report() = %+v, want planned dump only", report)
+	}
+	//neither dump, lock and tmp directory nor retention and quota deletions
+	if after := listFiles(t, filepath.Dir(global.Path)); !reflect.DeepEqual(after, before) {
+		t.Errorf("dry run changed files %v, want %v", after, before)
+	}
+
+	//today's daily exists, dump is not needed
+	writeDumps(t, global.Path, "pg/daily/"+time.Now().Format("2006-01-02"))
+	if err := dumper.Dump(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if dumper.Report().Planned {
+		t.Error("Report() planned dump, want not needed")
+	}
+}
@@ -1,6 +1,7 @@
 package dumper
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -11,21 +12,38 @@ import (
 )
 
 type Dumper interface {
-	Dump() error
+	//make dump and store it to enabled periods, cancelling ctx kills the dump command
+	Dump(ctx context.Context) error
+	//apply retention and quotas without making a dump
+	Prune() error
+	//result of the last Dump or Prune call
+	Report() Report
+
+	DumpNeeded() bool
+	NextDue() time.Time
+	RootPath() string
+	Inventory() ([]Artifact, error)
+	Find(reference string) (Artifact, error)
+	Verify() (map[string]error, error)
+	Restore(reference, destination string) (Artifact, error)
 }
 
 type AbstractDumper struct {
 	globalConfiguration GlobalConfiguration
 	configuration       Configuration
 	time                time.Time
+	checksum            string
+	report              Report
 
 	latest  PeriodDump
+	hourly  PeriodDump
 	daily   PeriodDump
 	weekly  PeriodDump
 	monthly PeriodDump
+	yearly  PeriodDump
 }
 
-func (dumper *AbstractDumper) execute(commandline string) error {
+func (dumper *AbstractDumper) execute(ctx context.Context, commandline string) error {
 	if len(dumper.configuration.Name) == 0 {
 		return errors.New("dumper name not defined")
 	}
@@ -36,147 +54,265 @@ func (dumper *AbstractDumper) execute(commandline string) error {
 		return errors.New("dumper tmp path not defined")
 	}
 
-	dumper.latest = PeriodDump{
-		name:                dumper.configuration.Name,
-		dumpType:            dumper.configuration.Type,
-		rootPath:            dumper.rootPath(),
-		fileName:            "latest",
-		tmpDumpFileName:     dumper.tmpDumpFileName(),
-		tmpLogFileName:      dumper.tmpLogFileName(),
-		tmpChecksumFileName: dumper.tmpChecksumFileName(),
-		maxItemsCount:       -1,
-		overwrite:           true,
-	}
-	dumper.daily = PeriodDump{
-		name:                dumper.configuration.Name,
-		dumpType:            dumper.configuration.Type,
-		rootPath:            fmt.Sprintf("%s%c%s", dumper.rootPath(), os.PathSeparator, "daily"),
-		fileName:            dumper.dailyFileName(),
-		tmpDumpFileName:     dumper.tmpDumpFileName(),
-		tmpLogFileName:      dumper.tmpLogFileName(),
-		tmpChecksumFileName: dumper.tmpChecksumFileName(),
-		maxItemsCount:       dumper.configuration.Days,
-		overwrite:           false,
+	dumper.report = Report{}
+	dumper.preparePeriods()
+
+	if dumper.globalConfiguration.DryRun {
+		return dumper.dryRun(commandline)
 	}
-	dumper.weekly = PeriodDump{
-		name:                dumper.configuration.Name,
-		dumpType:            dumper.configuration.Type,
-		rootPath:            fmt.Sprintf("%s%c%s", dumper.rootPath(), os.PathSeparator, "weekly"),
-		fileName:            dumper.weeklyFileName(),
-		tmpDumpFileName:     dumper.tmpDumpFileName(),
-		tmpLogFileName:      dumper.tmpLogFileName(),
-		tmpChecksumFileName: dumper.tmpChecksumFileName(),
-		maxItemsCount:       dumper.configuration.Weeks,
-		overwrite:           false,
+
+	unlock, err := dumper.lock(ctx)
+	if err != nil {
+		return err
 	}
-	dumper.monthly = PeriodDump{
-		name:                dumper.configuration.Name,
-		dumpType:            dumper.configuration.Type,
-		rootPath:            fmt.Sprintf("%s%c%s", dumper.rootPath(), os.PathSeparator, "monthly"),
-		fileName:            dumper.monthlyFileName(),
-		tmpDumpFileName:     dumper.tmpDumpFileName(),
-		tmpLogFileName:      dumper.tmpLogFileName(),
-		tmpChecksumFileName: dumper.tmpChecksumFileName(),
-		maxItemsCount:       dumper.configuration.Months,
-		overwrite:           false,
+	defer unlock()
+
+	if err := dumper.sweep(); err != nil {
+		return err
 	}
 
-	dumpNeeded := dumper.isDumpNeeded()
+	if !dumper.isDumpNeeded() {
+		log.Infof("%s (%s) no dump needed, skipping", dumper.configuration.Name, dumper.configuration.Type)
+		return dumper.finish()
+	}
 
-	if dumpNeeded {
-		defer func() {
-			log.Infof("%s (%s) clear tmp files...", dumper.configuration.Name, dumper.configuration.Type)
-			if err := dumper.clearTmpFiles(); err != nil {
-				log.Errorf("%s (%s) clear tmp files error: %s", dumper.configuration.Name, dumper.configuration.Type, err)
-			}
-		}()
+	defer func() {
+		dumper.report.LogTail = tailFile(dumper.tmpLogFileName(), logTailLines)
+		log.Infof("%s (%s) clear tmp files...", dumper.configuration.Name, dumper.configuration.Type)
+		if err := dumper.clearTmpFiles(); err != nil {
+			log.Errorf("%s (%s) clear tmp files error: %s", dumper.configuration.Name, dumper.configuration.Type, err)
+		}
+	}()
 
-		log.Infof("%s (%s) starting...", dumper.configuration.Name, dumper.configuration.Type)
+	ctx, cancel := dumper.withTimeout(ctx)
+	defer cancel()
 
-		if err := dumper.executeCommand(commandline); err != nil {
+	return dumper.withHooks(ctx, func() error {
+		if err := dumper.makeDump(ctx, commandline); err != nil {
 			return err
 		}
+		return dumper.finish()
+	})
+}
 
-		log.Infof("%s (%s) execution done", dumper.configuration.Name, dumper.configuration.Type)
+// makeDump executes dump command and stores the dump to enabled periods
+func (dumper *AbstractDumper) makeDump(ctx context.Context, commandline string) error {
+	if err := dumper.checkFreeSpace(); err != nil {
+		return err
+	}
 
-		if err := dumper.calculateChecksums(); err != nil {
-			return err
-		}
+	log.Infof("%s (%s) starting...", dumper.configuration.Name, dumper.configuration.Type)
 
-		log.Infof("%s (%s) checksums calculated", dumper.configuration.Name, dumper.configuration.Type)
-	} else {
-		log.Infof("%s (%s) no dump needed, skipping", dumper.configuration.Name, dumper.configuration.Type)
+	if err := dumper.executeWithRetries(ctx, commandline); err != nil {
+		return err
 	}
 
-	if dumper.configuration.Latest {
-		if dumpNeeded {
-			log.Infof("%s (%s) copy latest dump...", dumper.configuration.Name, dumper.configuration.Type)
-			if err := dumper.latest.execute(); err != nil {
-				return err
-			}
-		}
-	} else if dumper.latest.exists() {
-		log.Infof("%s (%s) remove latest dump...", dumper.configuration.Name, dumper.configuration.Type)
-		if err := dumper.latest.remove(); err != nil {
+	log.Infof("%s (%s) execution done", dumper.configuration.Name, dumper.configuration.Type)
+
+	if err := dumper.calculateChecksums(); err != nil {
+		return err
+	}
+
+	dumper.report.Dumped = true
+
+	log.Infof("%s (%s) checksums calculated", dumper.configuration.Name, dumper.configuration.Type)
+
+	if err := dumper.enforceQuotas(dumper.report.Size); err != nil {
+		return err
+	}
+
+	storage := dumper.storageMode()
+	sourceFileName := dumper.tmpDumpFileName()
+
+	if storage == StorageHardlink {
+		objectFileName, err := dumper.storeObject()
+		if err != nil {
 			return err
 		}
+		sourceFileName = objectFileName
 	}
 
-	if dumper.configuration.Daily {
-		if dumpNeeded {
-			log.Infof("%s (%s) copy daily dump...", dumper.configuration.Name, dumper.configuration.Type)
-			if err := dumper.daily.execute(); err != nil {
-				return err
-			}
+	for _, period := range dumper.periods() {
+		if !period.enabled {
+			continue
 		}
-		if err := dumper.daily.rotate(); err != nil {
+		if err := dumper.interrupted(ctx); err != nil {
 			return err
 		}
+		period.storage = storage
+		period.sourceFileName = sourceFileName
+
+		log.Infof("%s (%s) store %s dump (%s)...", dumper.configuration.Name, dumper.configuration.Type, period.periodName(), storage)
+		written := !period.exists() || period.overwrite
+		if err := period.execute(); err != nil {
+			return err
+		}
+		if written {
+			dumper.report.Files = append(dumper.report.Files, period.dumpFileName())
+		}
+	}
+
+	return nil
+}
+
+// finish applies retention after dump
+func (dumper *AbstractDumper) finish() error {
+	if err := dumper.applyRetention(); err != nil {
+		return err
+	}
+
+	log.Infof("%s (%s) done", dumper.configuration.Name, dumper.configuration.Type)
+
+	return nil
+}
+
+// Prune applies retention and quotas without making a dump
+func (dumper *AbstractDumper) Prune() error {
+	dumper.report = Report{}
+	dumper.preparePeriods()
+
+	if dumper.globalConfiguration.DryRun {
+		return dumper.dryRunRetention()
+	}
+
+	unlock, err := dumper.lock(context.Background())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := dumper.applyRetention(); err != nil {
+		return err
+	}
+	if err := dumper.enforceQuotas(0); err != nil {
+		return err
+	}
+
+	log.Infof("%s (%s) pruned", dumper.configuration.Name, dumper.configuration.Type)
+
+	return nil
+}
+
+// DumpNeeded returns true when the next Dump call will make a dump
+func (dumper *AbstractDumper) DumpNeeded() bool {
+	dumper.preparePeriods()
+	return dumper.isDumpNeeded()
+}
+
+// NextDue returns when the next Dump call will make a dump: now when it is needed,
+// otherwise beginning of the next period, zero time when dump is never needed again
+func (dumper *AbstractDumper) NextDue() time.Time {
+	dumper.preparePeriods()
+	if dumper.isDumpNeeded() {
+		return dumper.time
 	}
 
-	if dumper.configuration.Weekly {
-		if dumpNeeded {
-			log.Infof("%s (%s) copy weekly dump...", dumper.configuration.Name, dumper.configuration.Type)
-			if err := dumper.weekly.execute(); err != nil {
-				return err
-			}
+	var next time.Time
+	for _, period := range dumper.tiers() {
+		if !period.enabled {
+			continue
+		}
+		if start := period.kind.nextStart(dumper.time); next.IsZero() || start.Before(next) {
+			next = start
 		}
-		if err := dumper.weekly.rotate(); err != nil {
+	}
+	return next
+}
+
+func (dumper *AbstractDumper) RootPath() string {
+	return dumper.rootPath()
+}
+
+func (dumper *AbstractDumper) Report() Report {
+	return dumper.report
+}
+
+// applyRetention removes disabled latest dump and expired period dumps
+func (dumper *AbstractDumper) applyRetention() error {
+	if !dumper.latest.enabled && dumper.latest.exists() {
+		log.Infof("%s (%s) remove latest dump...", dumper.configuration.Name, dumper.configuration.Type)
+		if err := dumper.latest.remove(); err != nil {
 			return err
 		}
 	}
 
-	if dumper.configuration.Monthly {
-		if dumpNeeded {
-			log.Infof("%s (%s) copy monthly dump...", dumper.configuration.Name, dumper.configuration.Type)
-			if err := dumper.monthly.execute(); err != nil {
-				return err
-			}
+	for _, period := range dumper.tiers() {
+		if !period.enabled {
+			continue
 		}
-		if err := dumper.monthly.rotate(); err != nil {
+		rotated, err := period.rotate()
+		if err != nil {
 			return err
 		}
+		dumper.report.Rotated = append(dumper.report.Rotated, rotated...)
 	}
 
-	log.Infof("%s (%s) done", dumper.configuration.Name, dumper.configuration.Type)
+	return collectGarbage(dumper.objectsPath())
+}
 
-	return nil
+///////////////////////////////////////////////////////////////////////////////
+
+func (dumper *AbstractDumper) preparePeriods() {
+	conf := dumper.configuration
+
+	dumper.latest = PeriodDump{
+		name:                conf.Name,
+		dumpType:            conf.Type,
+		rootPath:            dumper.rootPath(),
+		fileName:            "latest",
+		tmpDumpFileName:     dumper.tmpDumpFileName(),
+		tmpLogFileName:      dumper.tmpLogFileName(),
+		tmpChecksumFileName: dumper.tmpChecksumFileName(),
+		enabled:             conf.Latest,
+		overwrite:           true,
+	}
+	dumper.hourly = dumper.newPeriod(periodHourly, conf.Hourly, retentionOf(conf.KeepHourly, conf.Hours, retentionHour))
+	dumper.daily = dumper.newPeriod(periodDaily, conf.Daily, retentionOf(conf.KeepDaily, conf.Days, retentionDay))
+	dumper.weekly = dumper.newPeriod(periodWeekly, conf.Weekly, retentionOf(conf.KeepWeekly, conf.Weeks, retentionWeek))
+	dumper.monthly = dumper.newPeriod(periodMonthly, conf.Monthly, retentionOf(conf.KeepMonthly, conf.Months, retentionMonth))
+	dumper.yearly = dumper.newPeriod(periodYearly, conf.Yearly, retentionOf(conf.KeepYearly, conf.Years, retentionYear))
+}
+
+func (dumper *AbstractDumper) newPeriod(kind *periodKind, enabled bool, retention Retention) PeriodDump {
+	return PeriodDump{
+		name:                dumper.configuration.Name,
+		dumpType:            dumper.configuration.Type,
+		rootPath:            fmt.Sprintf("%s%c%s", dumper.rootPath(), os.PathSeparator, kind.directory),
+		fileName:            kind.fileName(dumper.time),
+		tmpDumpFileName:     dumper.tmpDumpFileName(),
+		tmpLogFileName:      dumper.tmpLogFileName(),
+		tmpChecksumFileName: dumper.tmpChecksumFileName(),
+		kind:                kind,
+		time:                dumper.time,
+		retention:           retention,
+		enabled:             enabled,
+		overwrite:           dumper.globalConfiguration.Force,
+	}
+}
+
+// tiers returns dated periods, from shortest to longest
+func (dumper *AbstractDumper) tiers() []*PeriodDump {
+	return []*PeriodDump{&dumper.hourly, &dumper.daily, &dumper.weekly, &dumper.monthly, &dumper.yearly}
+}
+
+// periods returns latest and dated periods
+func (dumper *AbstractDumper) periods() []*PeriodDump {
+	return append([]*PeriodDump{&dumper.latest}, dumper.tiers()...)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
 func (dumper *AbstractDumper) isDumpNeeded() bool {
-	if dumper.configuration.Latest && dumper.configuration.ForceLatest {
+	if dumper.globalConfiguration.Force {
 		return true
 	}
-	if dumper.configuration.Daily && !dumper.daily.exists() {
-		return true
-	}
-	if dumper.configuration.Weekly && !dumper.weekly.exists() {
+	if dumper.configuration.Latest && dumper.configuration.ForceLatest {
 		return true
 	}
-	if dumper.configuration.Monthly && !dumper.monthly.exists() {
-		return true
+	for _, period := range dumper.tiers() {
+		if period.enabled && !period.exists() {
+			return true
+		}
 	}
 	return false
 }
@@ -215,46 +351,104 @@ func (dumper *AbstractDumper) tmpChecksumFileName() string {
 	return fmt.Sprintf("%s%c%s.checksum", dumper.tmpPath(), os.PathSeparator, dumper.configuration.Name)
 }
 
-func (dumper *AbstractDumper) clearTmpFiles() error {
-	if err := os.Remove(dumper.tmpDumpFileName()); err != nil {
-		return err
-	}
-	if err := os.Remove(dumper.tmpLogFileName()); err != nil {
-		return err
+// tmpDumpDirectory is intermediate directory of mongo dumpers
+func (dumper *AbstractDumper) tmpDumpDirectory() string {
+	return dumper.tmpDumpFileName() + "_dump"
+}
+
+func (dumper *AbstractDumper) tmpFileNames() []string {
+	return []string{
+		dumper.tmpDumpFileName(),
+		dumper.tmpLogFileName(),
+		dumper.tmpChecksumFileName(),
 	}
-	if err := os.Remove(dumper.tmpChecksumFileName()); err != nil {
+}
+
+func (dumper *AbstractDumper) clearTmpFiles() error {
+	if err := dumper.clearTmpDumpFiles(); err != nil {
 		return err
 	}
-	return nil
+	return removeIfExists(dumper.tmpLogFileName())
 }
 
-func (dumper *AbstractDumper) dailyFileName() string {
-	return dumper.time.Format("2006-01-02")
+// clearTmpDumpFiles removes tmp files of failed attempt, log file is kept
+func (dumper *AbstractDumper) clearTmpDumpFiles() error {
+	for _, fileName := range []string{dumper.tmpDumpFileName(), dumper.tmpChecksumFileName()} {
+		if err := removeIfExists(fileName); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(dumper.tmpDumpDirectory())
 }
 
-func (dumper *AbstractDumper) weeklyFileName() string {
-	year, week := dumper.time.ISOWeek()
-	return fmt.Sprintf("%04d-%02d", year, week)
-}
+// sweep removes leftovers of crashed runs from tmp path and period directories
+func (dumper *AbstractDumper) sweep() error {
+	for _, fileName := range dumper.tmpFileNames() {
+		if _, err := os.Stat(fileName); err != nil {
+			continue
+		}
+		log.Warnf("%s (%s) removing stale tmp file %s", dumper.configuration.Name, dumper.configuration.Type, fileName)
+		if err := removeIfExists(fileName); err != nil {
+			return err
+		}
+	}
+
+	tmpDumpDirectory := dumper.tmpDumpDirectory()
+	if _, err := os.Stat(tmpDumpDirectory); err == nil {
+		log.Warnf("%s (%s) removing stale tmp directory %s", dumper.configuration.Name, dumper.configuration.Type, tmpDumpDirectory)
+		if err := os.RemoveAll(tmpDumpDirectory); err != nil {
+			return err
+		}
+	}
+
+	objects := PeriodDump{
+		name:     dumper.configuration.Name,
+		dumpType: dumper.configuration.Type,
+		rootPath: dumper.objectsPath(),
+	}
+
+	for _, period := range append(dumper.periods(), &objects) {
+		if err := period.sweep(); err != nil {
+			return err
+		}
+	}
 
-func (dumper *AbstractDumper) monthlyFileName() string {
-	return dumper.time.Format("2006-01")
+	return nil
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
-func (dumper *AbstractDumper) executeCommand(commandline string) error {
-	logFile, err := os.OpenFile(dumper.tmpLogFileName(), os.O_WRONLY|os.O_CREATE, 0644)
+// executeCommand runs dump command, output of all attempts is collected in log file
+func (dumper *AbstractDumper) executeCommand(ctx context.Context, commandline string, attempt int) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if attempt > 1 {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	logFile, err := os.OpenFile(dumper.tmpLogFileName(), flags, 0644)
 	if err != nil {
 		return err
 	}
 	defer logFile.Close()
 
-	cmd := exec.Command(dumper.globalConfiguration.ShExecutable, "-c", commandline)
+	if dumper.configuration.Retries > 0 {
+		header := fmt.Sprintf("=== attempt %d of %d, %s ===\n", attempt, dumper.configuration.Retries+1, time.Now().Format(time.RFC3339))
+		if attempt > 1 {
+			header = "\n" + header
+		}
+		if _, err := logFile.WriteString(header); err != nil {
+			return err
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, dumper.globalConfiguration.ShExecutable, "-c", commandline)
 	cmd.Stdout = logFile
 	cmd.Stderr = logFile
+	killProcessGroup(cmd)
 
 	if err := cmd.Run(); err != nil {
+		if ctxErr := dumper.interrupted(ctx); ctxErr != nil {
+			return ctxErr
+		}
 		return err
 	}
 
@@ -262,6 +456,8 @@ func (dumper *AbstractDumper) executeCommand(commandline string) error {
 	if err != nil {
 		return err
 	}
+	dumper.report.Size = stat.Size()
+
 	if stat.Size() == 0 {
 		return errors.New("empty dump file")
 	} else {
@@ -287,6 +483,10 @@ func (dumper *AbstractDumper) calculateChecksums() error {
 		return err
 	}
 
+	dumper.checksum = sha256Hash
+	dumper.report.Checksum = sha256Hash
+	dumper.report.Checksums = map[string]string{HashMD5: md5Hash, HashSha1: sha1Hash, HashSha256: sha256Hash}
+
 	output := fmt.Sprintf("MD5: %s\nSHA1: %s\nSHA256: %s\n", md5Hash, sha1Hash, sha256Hash)
 
 	if err := os.WriteFile(dumper.tmpChecksumFileName(), []byte(output), 0644); err != nil {
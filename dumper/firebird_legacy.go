@@ -1,6 +1,7 @@
 package dumper
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -27,7 +28,7 @@ func NewFirebirdLegacy(global GlobalConfiguration, local Configuration) (*Firebi
 	return &dumper, nil
 }
 
-func (dumper *FirebirdLegacyDumper) Dump() error {
+func (dumper *FirebirdLegacyDumper) Dump(ctx context.Context) error {
 	stringBuilder := strings.Builder{}
 
 	//https://github.com/FirebirdSQL/firebird/releases/tag/R2_5_9
@@ -74,5 +75,5 @@ func (dumper *FirebirdLegacyDumper) Dump() error {
 
 	stringBuilder.WriteString(fmt.Sprintf("%s \"%s\"", source, esc(dumper.tmpDumpFileName())))
 
-	return dumper.execute(stringBuilder.String())
+	return dumper.execute(ctx, stringBuilder.String())
 }
@@ -0,0 +1,139 @@
+package dumper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultHookTimeout = 5 * time.Minute
+
+const (
+	HookBefore    = "before"
+	HookAfter     = "after"
+	HookOnSuccess = "on-success"
+	HookOnFailure = "on-failure"
+)
+
+type hook struct {
+	name    string
+	command string
+}
+
+// hooks returns configured hooks in order of execution
+func (dumper *AbstractDumper) hooks() []hook {
+	return []hook{
+		{HookBefore, dumper.configuration.Before},
+		{HookOnSuccess, dumper.configuration.OnSuccess},
+		{HookOnFailure, dumper.configuration.OnFailure},
+		{HookAfter, dumper.configuration.After},
+	}
+}
+
+// withHooks runs dump between before and after hooks,
+// failed before hook aborts the dump, errors of hooks after failed dump are added to dump error,
+// errors of hooks after stored dump are warnings in report.
+// on-failure and after hooks run even when ctx is cancelled, they usually undo what before did
+func (dumper *AbstractDumper) withHooks(ctx context.Context, dump func() error) error {
+	err := dumper.runHook(ctx, HookBefore, dumper.configuration.Before, nil)
+	if err == nil {
+		err = dump()
+	}
+
+	if err != nil {
+		return errors.Join(err,
+			dumper.runHook(context.Background(), HookOnFailure, dumper.configuration.OnFailure, err),
+			dumper.runHook(context.Background(), HookAfter, dumper.configuration.After, err))
+	}
+
+	for _, hookErr := range []error{
+		dumper.runHook(ctx, HookOnSuccess, dumper.configuration.OnSuccess, nil),
+		dumper.runHook(context.Background(), HookAfter, dumper.configuration.After, nil),
+	} {
+		if hookErr != nil {
+			log.Warnf("%s (%s) dump stored, but %s", dumper.configuration.Name, dumper.configuration.Type, hookErr)
+			dumper.report.HookErrors = append(dumper.report.HookErrors, hookErr.Error())
+		}
+	}
+
+	return nil
+}
+
+// runHook executes hook command with environment describing the dump
+func (dumper *AbstractDumper) runHook(ctx context.Context, hook, command string, dumpErr error) error {
+	if len(command) == 0 {
+		return nil
+	}
+
+	name := dumper.configuration.Name
+	dumpType := dumper.configuration.Type
+
+	timeout := dumper.configuration.HookTimeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+
+	hookCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	log.Infof("%s (%s) running %s hook...", name, dumpType, hook)
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(hookCtx, dumper.globalConfiguration.ShExecutable, "-c", command)
+	cmd.Env = append(os.Environ(), dumper.hookEnvironment(hook, dumpErr)...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	killProcessGroup(cmd)
+	//children which left the process group may keep output open
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+
+	for _, line := range strings.Split(strings.TrimRight(output.String(), "\n"), "\n") {
+		if len(line) != 0 {
+			log.Infof("%s (%s) %s hook: %s", name, dumpType, hook, line)
+		}
+	}
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("%s hook aborted: %w", hook, ctx.Err())
+	}
+	if hookCtx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s hook timed out after %s", hook, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s hook failed: %s", hook, err)
+	}
+
+	return nil
+}
+
+func (dumper *AbstractDumper) hookEnvironment(hook string, dumpErr error) []string {
+	status := "success"
+	errorMessage := ""
+	if dumpErr != nil {
+		status = "failure"
+		errorMessage = dumpErr.Error()
+	}
+
+	return []string{
+		"BOX_HOOK=" + hook,
+		"BOX_NAME=" + dumper.configuration.Name,
+		"BOX_TYPE=" + string(dumper.configuration.Type),
+		"BOX_TAGS=" + strings.Join(dumper.configuration.Tags, ","),
+		"BOX_PATH=" + dumper.rootPath(),
+		"BOX_TMP_FILE=" + dumper.tmpDumpFileName(),
+		"BOX_FILES=" + strings.Join(dumper.report.Files, string(os.PathListSeparator)),
+		fmt.Sprintf("BOX_SIZE=%d", dumper.report.Size),
+		"BOX_CHECKSUM=" + dumper.report.Checksum,
+		"BOX_STATUS=" + status,
+		"BOX_ERROR=" + errorMessage,
+	}
+}
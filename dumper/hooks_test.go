@@ -0,0 +1,112 @@
+package dumper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_withHooks(t *testing.T) {
+	tests := []struct {
+		name       string
+		before     string
+		after      string
+		dumpErr    error
+		expected   string
+		dumped     bool
+		failed     bool
+		hookErrors []string
+	}{
+		{
+			name:     "success",
+			before:   "echo before >> $OUT",
+			expected: "before\ndump\non-success success\nafter success\n",
+			dumped:   true,
+		}, {
+			name:     "dump failed",
+			before:   "echo before >> $OUT",
+			dumpErr:  errors.New("broken"),
+			expected: "before\ndump\non-failure broken\nafter failure\n",
+			dumped:   true,
+			failed:   true,
+		}, {
+			name:       "after failed",
+			before:     "echo before >> $OUT",
+			after:      "echo after $BOX_STATUS >> $OUT; exit 2",
+			expected:   "before\ndump\non-success success\nafter success\n",
+			dumped:     true,
+			hookErrors: []string{"after hook failed: exit status 2"},
+		}, {
+			name:     "after failed with dump",
+			before:   "echo before >> $OUT",
+			after:    "echo after $BOX_STATUS >> $OUT; exit 2",
+			dumpErr:  errors.New("broken"),
+			expected: "before\ndump\non-failure broken\nafter failure\n",
+			dumped:   true,
+			failed:   true,
+		}, {
+			name:     "before failed",
+			before:   "exit 1",
+			expected: "on-failure before hook failed: exit status 1\nafter failure\n",
+			failed:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out")
+			t.Setenv("OUT", out)
+
+			after := `echo after $BOX_STATUS >> $OUT`
+			if len(tt.after) != 0 {
+				after = tt.after
+			}
+
+			dumper := AbstractDumper{
+				globalConfiguration: GlobalConfiguration{ShExecutable: "sh"},
+				configuration: Configuration{
+					Name:      "test",
+					Before:    tt.before,
+					OnSuccess: `echo on-success $BOX_STATUS >> $OUT`,
+					OnFailure: `echo on-failure "$BOX_ERROR" >> $OUT`,
+					After:     after,
+				},
+			}
+
+			dumped := false
+			err := dumper.withHooks(context.Background(), func() error {
+				dumped = true
+				f, err := os.OpenFile(out, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				_, _ = f.WriteString("dump\n")
+				return tt.dumpErr
+			})
+
+			if dumped != tt.dumped {
+				t.Errorf("dumped = %v, want %v", dumped, tt.dumped)
+			}
+			if (err != nil) != tt.failed {
+				t.Errorf("err = %v, want failed %v", err, tt.failed)
+			}
+			if !reflect.DeepEqual(dumper.report.HookErrors, tt.hookErrors) {
+				t.Errorf("hook errors = %v, want %v", dumper.report.HookErrors, tt.hookErrors)
+			}
+			content, _ := os.ReadFile(out)
+			if string(content) != tt.expected {
+				t.Errorf("hooks output = %q, want %q", content, tt.expected)
+			}
+			//error passed to hooks is the dump error, without errors of hooks after it
+			if tt.failed && err != nil {
+				if cause, _, _ := strings.Cut(err.Error(), "\n"); !strings.Contains(string(content), cause) {
+					t.Errorf("error %q not passed to hooks", cause)
+				}
+			}
+		})
+	}
+}
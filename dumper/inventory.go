@@ -0,0 +1,246 @@
+package dumper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PeriodLatest is the period name of the latest dump
+const PeriodLatest = "latest"
+
+// Artifact is a stored dump file
+type Artifact struct {
+	//latest, hourly, daily, weekly, monthly or yearly
+	Period   string
+	FileName string
+	Path     string
+	//beginning of the period, modification time for the latest dump
+	Time time.Time
+	Size int64
+
+	rootPath string
+}
+
+func (a *Artifact) LogPath() string {
+	return a.Path + ".log"
+}
+
+func (a *Artifact) ChecksumPath() string {
+	return a.Path + ".checksum"
+}
+
+// Checksums returns checksums of checksum file by hash type: md5, sha1, sha256
+func (a *Artifact) Checksums() (map[string]string, error) {
+	return readChecksums(a.ChecksumPath())
+}
+
+// Reference returns artifact name relative to dump path, e.g. latest or daily/2026-10-17
+func (a *Artifact) Reference() string {
+	if a.Period == PeriodLatest {
+		return PeriodLatest
+	}
+	return fmt.Sprintf("%s/%s", a.Period, a.FileName)
+}
+
+// collectArtifacts returns dated dumps of all period directories under dump root path
+func collectArtifacts(rootPath string) ([]Artifact, error) {
+	var artifacts []Artifact
+
+	for _, kind := range periodKinds {
+		directory := fmt.Sprintf("%s%c%s", rootPath, os.PathSeparator, kind.directory)
+		files, err := os.ReadDir(directory)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+			fileTime, ok := kind.parse(file.Name())
+			if !ok {
+				continue
+			}
+			info, err := file.Info()
+			if err != nil {
+				return nil, err
+			}
+			artifacts = append(artifacts, Artifact{
+				Period:   kind.directory,
+				FileName: file.Name(),
+				Path:     fmt.Sprintf("%s%c%s", directory, os.PathSeparator, file.Name()),
+				Time:     fileTime,
+				Size:     info.Size(),
+				rootPath: rootPath,
+			})
+		}
+	}
+
+	return artifacts, nil
+}
+
+// removeArtifact deletes dump file with its log and checksum, releases unreferenced objects
+func removeArtifact(a Artifact) error {
+	if err := os.Remove(a.Path); err != nil {
+		return err
+	}
+	if err := removeIfExists(a.LogPath()); err != nil {
+		return err
+	}
+	if err := removeIfExists(a.ChecksumPath()); err != nil {
+		return err
+	}
+	return collectGarbage(fmt.Sprintf("%s%c%s", a.rootPath, os.PathSeparator, objectsDirectoryName))
+}
+
+// readChecksums parses checksum file written by calculateChecksums
+func readChecksums(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string)
+
+	for _, line := range strings.Split(string(content), "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		checksums[strings.ToLower(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+	}
+
+	return checksums, nil
+}
+
+// verifyArtifact compares SHA256 of artifact with the stored checksum file
+func verifyArtifact(a Artifact) error {
+	checksums, err := readChecksums(a.ChecksumPath())
+	if err != nil {
+		return fmt.Errorf("unable to read checksum file: %s", err)
+	}
+	expected, ok := checksums[HashSha256]
+	if !ok {
+		return errors.New("checksum file has no SHA256")
+	}
+	actual, err := fileChecksum(HashSha256, a.Path)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expected, actual)
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// Inventory returns stored dumps: latest first, then periods from shortest, newest first
+func (dumper *AbstractDumper) Inventory() ([]Artifact, error) {
+	var artifacts []Artifact
+
+	latestPath := fmt.Sprintf("%s%c%s", dumper.rootPath(), os.PathSeparator, PeriodLatest)
+	if info, err := os.Stat(latestPath); err == nil {
+		artifacts = append(artifacts, Artifact{
+			Period:   PeriodLatest,
+			FileName: PeriodLatest,
+			Path:     latestPath,
+			Time:     info.ModTime(),
+			Size:     info.Size(),
+			rootPath: dumper.rootPath(),
+		})
+	}
+
+	dated, err := collectArtifacts(dumper.rootPath())
+	if err != nil {
+		return nil, err
+	}
+
+	order := make(map[string]int)
+	for i, kind := range periodKinds {
+		order[kind.directory] = i
+	}
+
+	sort.SliceStable(dated, func(i, j int) bool {
+		if dated[i].Period != dated[j].Period {
+			return order[dated[i].Period] < order[dated[j].Period]
+		}
+		return dated[i].Time.After(dated[j].Time)
+	})
+
+	return append(artifacts, dated...), nil
+}
+
+// Find returns stored dump by reference: latest, period name (the newest of period) or period/file
+func (dumper *AbstractDumper) Find(reference string) (Artifact, error) {
+	artifacts, err := dumper.Inventory()
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	reference = filepath.ToSlash(strings.Trim(reference, "/"))
+
+	for _, a := range artifacts {
+		if a.Reference() == reference || a.Period == reference {
+			return a, nil
+		}
+	}
+
+	return Artifact{}, fmt.Errorf("dump %s not found", reference)
+}
+
+// Verify checks stored dumps against their checksum files, returns errors by artifact path,
+// dump is locked, so running dump does not rotate files being verified
+func (dumper *AbstractDumper) Verify() (map[string]error, error) {
+	unlock, err := dumper.lock(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	artifacts, err := dumper.Inventory()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]error)
+	for _, a := range artifacts {
+		results[a.Path] = verifyArtifact(a)
+	}
+
+	return results, nil
+}
+
+// Restore verifies stored dump and copies it to destination
+func (dumper *AbstractDumper) Restore(reference, destination string) (Artifact, error) {
+	a, err := dumper.Find(reference)
+	if err != nil {
+		return a, err
+	}
+	if err := verifyArtifact(a); err != nil {
+		return a, fmt.Errorf("%s: %s", a.Path, err)
+	}
+	if _, err := os.Stat(destination); err == nil {
+		return a, fmt.Errorf("destination %s already exists", destination)
+	}
+
+	staging := stagingFileName(destination)
+	defer removeIfExists(staging)
+
+	if err := copyFile(a.Path, staging); err != nil {
+		return a, err
+	}
+	if err := os.Rename(staging, destination); err != nil {
+		return a, err
+	}
+
+	return a, nil
+}
@@ -0,0 +1,152 @@
+package dumper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const lockPollInterval = time.Second
+
+// ErrLocked is returned when lock is held by another process
+var ErrLocked = errors.New("locked")
+
+// Lock is a lock file, exclusive lock holder writes its PID and start time to the file
+type Lock struct {
+	file      *os.File
+	exclusive bool
+}
+
+// AcquireLock locks file at path, waiting up to wait while another process holds the lock
+func AcquireLock(ctx context.Context, path string, exclusive bool, wait time.Duration) (*Lock, error) {
+	if err := makeDirectory(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open lock file: %s", err)
+	}
+
+	deadline := time.Now().Add(wait)
+
+	for {
+		locked, err := tryLock(file, exclusive)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("unable to lock %s: %s", path, err)
+		}
+		if locked {
+			break
+		}
+
+		if !time.Now().Before(deadline) {
+			holder := "by another box process"
+			if pid, started, ok := lockHolder(file); ok {
+				holder = fmt.Sprintf("by pid %d since %s", pid, started.Format(time.RFC3339))
+			}
+			file.Close()
+			return nil, fmt.Errorf("%s %w %s", path, ErrLocked, holder)
+		}
+
+		select {
+		case <-ctx.Done():
+			file.Close()
+			return nil, fmt.Errorf("waiting for lock %s: %w", path, ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	lock := &Lock{file: file, exclusive: exclusive}
+
+	if exclusive {
+		if pid, started, ok := lockHolder(file); ok && pid != os.Getpid() {
+			log.Warnf("reclaiming stale lock %s of pid %d started at %s", path, pid, started.Format(time.RFC3339))
+		}
+		if err := lock.writeHolder(); err != nil {
+			_ = lock.Release()
+			return nil, fmt.Errorf("unable to write lock file: %s", err)
+		}
+	}
+
+	return lock, nil
+}
+
+// Release unlocks the file, lock file is not deleted, another process may wait for it
+func (lock *Lock) Release() error {
+	if lock.exclusive {
+		if err := lock.file.Truncate(0); err != nil {
+			log.Errorf("unable to clear lock file %s: %s", lock.file.Name(), err)
+		}
+	}
+	if err := unlock(lock.file); err != nil {
+		lock.file.Close()
+		return err
+	}
+	return lock.file.Close()
+}
+
+func (lock *Lock) writeHolder() error {
+	if err := lock.file.Truncate(0); err != nil {
+		return err
+	}
+	content := fmt.Sprintf("%d %s\n", os.Getpid(), time.Now().Format(time.RFC3339))
+	if _, err := lock.file.WriteAt([]byte(content), 0); err != nil {
+		return err
+	}
+	return lock.file.Sync()
+}
+
+// lockHolder returns PID and start time written to lock file by exclusive lock holder
+func lockHolder(file *os.File) (int, time.Time, bool) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, time.Time{}, false
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+
+	fields := strings.Fields(string(content))
+	if len(fields) != 2 {
+		return 0, time.Time{}, false
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	started, err := time.Parse(time.RFC3339, fields[1])
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+
+	return pid, started, true
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (dumper *AbstractDumper) lockFileName() string {
+	return fmt.Sprintf("%s%c%s.lock", dumper.tmpPath(), os.PathSeparator, dumper.configuration.Name)
+}
+
+// lock takes exclusive lock of dump, so concurrent runs do not share tmp files and periods
+func (dumper *AbstractDumper) lock(ctx context.Context) (func(), error) {
+	lock, err := AcquireLock(ctx, dumper.lockFileName(), true, dumper.globalConfiguration.LockWait)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if err := lock.Release(); err != nil {
+			log.Errorf("%s (%s) unable to release lock: %s", dumper.configuration.Name, dumper.configuration.Type, err)
+		}
+	}, nil
+}
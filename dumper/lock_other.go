@@ -0,0 +1,24 @@
+//go:build !unix
+
+package dumper
+
+import (
+	"os"
+)
+
+// tryLock checks PID written by exclusive lock holder is not running, shared locks are not supported
+func tryLock(file *os.File, exclusive bool) (bool, error) {
+	if !exclusive {
+		return true, nil
+	}
+	pid, _, ok := lockHolder(file)
+	if !ok || pid == os.Getpid() {
+		return true, nil
+	}
+	_, err := os.FindProcess(pid)
+	return err != nil, nil
+}
+
+func unlock(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,51 @@
+//go:build unix
+
+package dumper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAcquireLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	lock, err := AcquireLock(context.Background(), path, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pid, _, ok := lockHolder(lock.file)
+	if !ok || pid != os.Getpid() {
+		t.Errorf("lock holder = %d, %v, want %d", pid, ok, os.Getpid())
+	}
+
+	//flock is held by open file, so the second open file conflicts even in the same process
+	if _, err := AcquireLock(context.Background(), path, true, 0); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrLocked, got %v", err)
+	}
+	if _, err := AcquireLock(context.Background(), path, false, 0); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrLocked for shared lock, got %v", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	shared1, err := AcquireLock(context.Background(), path, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared2, err := AcquireLock(context.Background(), path, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AcquireLock(context.Background(), path, true, 0); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrLocked while shared locks are held, got %v", err)
+	}
+	_ = shared1.Release()
+	_ = shared2.Release()
+}
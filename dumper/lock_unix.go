@@ -0,0 +1,26 @@
+//go:build unix
+
+package dumper
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLock takes flock without waiting, the kernel releases it when holder process dies
+func tryLock(file *os.File, exclusive bool) (bool, error) {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	err := syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlock(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -1,5 +1,11 @@
 package dumper
 
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
 type Type string
 
 const (
@@ -11,6 +17,105 @@ const (
 	TypeTar            Type = "tar"
 )
 
+// Types lists supported dumper types
+var Types = []Type{TypePostgres, TypeMysql, TypeMongo, TypeMongoLegacy, TypeFirebirdLegacy, TypeTar}
+
+// RequiredVars returns variables a dumper of type can not work without
+func RequiredVars(dumpType Type) []string {
+	switch dumpType {
+	case TypeMysql:
+		return []string{"database"}
+	case TypeFirebirdLegacy:
+		return []string{"db"}
+	case TypeTar:
+		return []string{"path"}
+	default:
+		return nil
+	}
+}
+
+// Executables returns executables a dumper of type runs, besides shell
+func Executables(global GlobalConfiguration, dumpType Type) []string {
+	switch dumpType {
+	case TypePostgres:
+		return []string{global.PgdumpExecutable, "gzip"}
+	case TypeMysql:
+		return []string{global.MysqldumpExecutable, "gzip"}
+	case TypeMongo:
+		return []string{global.Mongodump5Executable, "tar", "rm"}
+	case TypeMongoLegacy:
+		return []string{global.Mongodump4Executable, "tar", "rm"}
+	case TypeFirebirdLegacy:
+		return []string{global.GbakExecutable}
+	case TypeTar:
+		return []string{global.TarExecutable}
+	default:
+		return nil
+	}
+}
+
+// RootPath returns directory where dumps are stored
+func RootPath(global GlobalConfiguration, local Configuration) string {
+	dumper := AbstractDumper{globalConfiguration: global, configuration: local}
+	return dumper.rootPath()
+}
+
+// TmpDumpFileName returns temporary file the dump is written to
+func TmpDumpFileName(global GlobalConfiguration, local Configuration) string {
+	dumper := AbstractDumper{globalConfiguration: global, configuration: local}
+	return dumper.tmpDumpFileName()
+}
+
+const (
+	NotifyAll      = "all"
+	NotifyFailures = "failures"
+	NotifyChanges  = "changes"
+)
+
+// NotificationPolicy decides which notifications about dumps are sent
+type NotificationPolicy struct {
+	//all (default), failures (warnings and errors) or changes (status differs from the last run)
+	Send string `yaml:"send"`
+
+	//failure with the same message as the last run is not sent again
+	SuppressRepeats bool `yaml:"suppress-repeats"`
+
+	//suppressed failure is sent again as a reminder after duration, never when 0
+	RemindAfter time.Duration `yaml:"remind-after"`
+
+	//time of day (15:04) after which the first run sends summary of all dumps, global only
+	DailySummary string `yaml:"daily-summary"`
+}
+
+// Heartbeat pings monitoring service like healthchecks.io or Uptime Kuma when dump or run starts and finishes
+type Heartbeat struct {
+	//success ping url, start ping is url/start and failure ping is url/fail unless they are set
+	Url string `yaml:"url"`
+
+	StartUrl   string `yaml:"start-url"`
+	SuccessUrl string `yaml:"success-url"`
+	FailureUrl string `yaml:"failure-url"`
+}
+
+// PingUrls returns urls of start, success and failure pings, empty url is not pinged
+func (heartbeat Heartbeat) PingUrls() (start, success, failure string) {
+	start, success, failure = heartbeat.StartUrl, heartbeat.SuccessUrl, heartbeat.FailureUrl
+	if len(heartbeat.Url) == 0 {
+		return
+	}
+	url := strings.TrimRight(heartbeat.Url, "/")
+	if len(start) == 0 {
+		start = url + "/start"
+	}
+	if len(success) == 0 {
+		success = url
+	}
+	if len(failure) == 0 {
+		failure = url + "/fail"
+	}
+	return
+}
+
 type GlobalConfiguration struct {
 	Path                 string `yaml:"path"`
 	TmpPath              string `yaml:"tmp-path"`
@@ -21,12 +126,42 @@ type GlobalConfiguration struct {
 	Mongodump4Executable string `yaml:"mongodump-4-executable"`
 	GbakExecutable       string `yaml:"gbak-executable"`
 	TarExecutable        string `yaml:"tar-executable"`
+
+	//how dumps are stored in periods: copy, hardlink or reflink
+	Storage StorageMode `yaml:"storage"`
+
+	//total size of all dumps in path, the oldest dumps are pruned to fit
+	MaxTotalSize Size `yaml:"max-total-size"`
+
+	//time limit of every dump, unless dump sets its own
+	Timeout time.Duration `yaml:"timeout"`
+
+	//time limit of the whole run
+	RunTimeout time.Duration `yaml:"run-timeout"`
+
+	//how long to wait for dumps locked by another box process, locked dumps are skipped when 0
+	LockWait time.Duration `yaml:"lock-wait"`
+
+	//print what would be done, without executing anything
+	DryRun bool `yaml:"-"`
+
+	//make dumps even when they are not needed, files of current periods are overwritten
+	Force bool `yaml:"-"`
+
+	//values resolved from configuration references, masked in output
+	Secrets []string `yaml:"-"`
+
+	//all configured dumps, global max-total-size prunes only their files
+	Dumps []Configuration `yaml:"-"`
 }
 
 type Configuration struct {
 	Type Type   `yaml:"type"`
 	Name string `yaml:"name"`
 
+	//labels for selecting dumps from command line
+	Tags []string `yaml:"tags"`
+
 	//override destination path
 	//when empty, path will be global path + dumper name
 	Path string `yaml:"path"`
@@ -34,24 +169,122 @@ type Configuration struct {
 	//override tmp path
 	TmpPath string `yaml:"tmp-path"`
 
+	//override global storage mode
+	Storage StorageMode `yaml:"storage"`
+
+	//total size of dumps in path, the oldest dumps are pruned to fit
+	MaxTotalSize Size `yaml:"max-total-size"`
+
 	//variables to pass to dump executable
 	Vars map[string]string `yaml:"vars"`
 
+	//shell command run before dump, dump is aborted when it fails
+	Before string `yaml:"before"`
+
+	//shell command run after dump, whether it succeeded or not
+	After string `yaml:"after"`
+
+	//shell command run after successful dump
+	OnSuccess string `yaml:"on-success"`
+
+	//shell command run after failed dump
+	OnFailure string `yaml:"on-failure"`
+
+	//time limit of every hook command, 5m by default
+	HookTimeout time.Duration `yaml:"hook-timeout"`
+
+	//time limit of dump command and copying to periods, dump is killed when it is exceeded
+	Timeout time.Duration `yaml:"timeout"`
+
+	//pings when dump starts and finishes
+	Heartbeat Heartbeat `yaml:"heartbeat"`
+
+	//replaces global notification policy
+	NotificationPolicy *NotificationPolicy `yaml:"notification-policy"`
+
+	//number of times failed dump command is retried
+	Retries int `yaml:"retries"`
+
+	//delay before the first retry, doubled for every next retry, 30s by default
+	RetryDelay time.Duration `yaml:"retry-delay"`
+
 	//keep latest dump
 	Latest bool `yaml:"latest"`
 
-	//(if Latest is set) always make the latest dump, even if daily/weekly/monthly dumps exist
+	//(if Latest is set) always make the latest dump, even if hourly/daily/weekly/monthly/yearly dumps exist
 	ForceLatest bool `yaml:"force-latest"`
 
+	//make hourly dumps
+	Hourly bool `yaml:"hourly"`
+
+	//keep hourly dumps for number of hours, -1 to keep forever (when KeepHourly is not set)
+	Hours int `yaml:"hours"`
+
+	//keep hourly dumps for duration: 48h, 14d, 8w, 12mo, 7y or forever
+	KeepHourly Retention `yaml:"keep-hourly"`
+
 	//make daily dumps
 	Daily bool `yaml:"daily"`
-	Days  int  `yaml:"days"`
+
+	//keep daily dumps for number of days, -1 to keep forever (when KeepDaily is not set)
+	Days int `yaml:"days"`
+
+	//keep daily dumps for duration: 48h, 14d, 8w, 12mo, 7y or forever
+	KeepDaily Retention `yaml:"keep-daily"`
 
 	//make weekly dumps
 	Weekly bool `yaml:"weekly"`
-	Weeks  int  `yaml:"weeks"`
+
+	//keep weekly dumps for number of weeks, -1 to keep forever (when KeepWeekly is not set)
+	Weeks int `yaml:"weeks"`
+
+	//keep weekly dumps for duration: 48h, 14d, 8w, 12mo, 7y or forever
+	KeepWeekly Retention `yaml:"keep-weekly"`
 
 	//make monthly dumps
 	Monthly bool `yaml:"monthly"`
-	Months  int  `yaml:"months"`
+
+	//keep monthly dumps for number of months, -1 to keep forever (when KeepMonthly is not set)
+	Months int `yaml:"months"`
+
+	//keep monthly dumps for duration: 48h, 14d, 8w, 12mo, 7y or forever
+	KeepMonthly Retention `yaml:"keep-monthly"`
+
+	//make yearly dumps
+	Yearly bool `yaml:"yearly"`
+
+	//keep yearly dumps for number of years, -1 to keep forever (when KeepYearly is not set)
+	Years int `yaml:"years"`
+
+	//keep yearly dumps for duration: 48h, 14d, 8w, 12mo, 7y or forever
+	KeepYearly Retention `yaml:"keep-yearly"`
+}
+
+// New creates dumper of configured type
+func New(global GlobalConfiguration, local Configuration) (Dumper, error) {
+	var dumper Dumper
+	var err error
+
+	switch local.Type {
+	case TypePostgres:
+		dumper, err = NewPostgres(global, local)
+	case TypeMongo:
+		dumper, err = NewMongo5(global, local)
+	case TypeMongoLegacy:
+		dumper, err = NewMongo4(global, local)
+	case TypeFirebirdLegacy:
+		dumper, err = NewFirebirdLegacy(global, local)
+	case TypeMysql:
+		dumper, err = NewMysql(global, local)
+	case TypeTar:
+		dumper, err = NewTar(global, local)
+	default:
+		err = errors.New("unknown dumper type")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dumper, nil
 }
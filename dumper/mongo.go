@@ -1,6 +1,7 @@
 package dumper
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -27,7 +28,7 @@ func NewMongo5(global GlobalConfiguration, local Configuration) (*Mongo5Dumper,
 	return &dumper, nil
 }
 
-func (dumper *Mongo5Dumper) Dump() error {
+func (dumper *Mongo5Dumper) Dump(ctx context.Context) error {
 	//https://docs.mongodb.com/database-tools/mongodump/
 	//Compatible with MongoDB 5.0-4.0
 	//Example configuration:
@@ -40,7 +41,7 @@ func (dumper *Mongo5Dumper) Dump() error {
 
 	commandline := buildMongoCommandline(dumper.globalConfiguration.Mongodump5Executable, dumper.tmpDumpFileName(), dumper.configuration.Vars)
 
-	return dumper.execute(commandline)
+	return dumper.execute(ctx, commandline)
 }
 
 func buildMongoCommandline(executable, dumpFileName string, vars map[string]string) string {
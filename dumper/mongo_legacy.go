@@ -1,6 +1,7 @@
 package dumper
 
 import (
+	"context"
 	"errors"
 	"time"
 )
@@ -25,7 +26,7 @@ func NewMongo4(global GlobalConfiguration, local Configuration) (*Mongo4Dumper,
 	return &dumper, nil
 }
 
-func (dumper *Mongo4Dumper) Dump() error {
+func (dumper *Mongo4Dumper) Dump(ctx context.Context) error {
 	//https://docs.mongodb.com/v4.0/reference/program/mongodump/
 	//Compatible with MongoDB 4.0-2.6
 	//Example configuration:
@@ -38,5 +39,5 @@ func (dumper *Mongo4Dumper) Dump() error {
 
 	commandline := buildMongoCommandline(dumper.globalConfiguration.Mongodump4Executable, dumper.tmpDumpFileName(), dumper.configuration.Vars)
 
-	return dumper.execute(commandline)
+	return dumper.execute(ctx, commandline)
 }
@@ -1,6 +1,7 @@
 package dumper
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -27,7 +28,7 @@ func NewMysql(global GlobalConfiguration, local Configuration) (*MysqlDumper, er
 	return &dumper, nil
 }
 
-func (d *MysqlDumper) Dump() error {
+func (d *MysqlDumper) Dump(ctx context.Context) error {
 	sb := strings.Builder{}
 	sb.WriteString(fmt.Sprintf("\"%s\" --verbose ", d.globalConfiguration.MysqldumpExecutable))
 
@@ -53,7 +54,9 @@ func (d *MysqlDumper) Dump() error {
 		sb.WriteString(" ")
 	}
 
-	sb.WriteString(fmt.Sprintf(" \"%s\" | gzip > \"%s\"", esc(database), esc(d.tmpDumpFileName())))
+	sb.WriteString(fmt.Sprintf(" \"%s\"", esc(database)))
 
-	return d.execute(sb.String())
+	commandline := pipeline(sb.String(), fmt.Sprintf("gzip > \"%s\"", esc(d.tmpDumpFileName())))
+
+	return d.execute(ctx, commandline)
 }
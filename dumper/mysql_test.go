@@ -0,0 +1,41 @@
+package dumper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMysqlDumper_Dump(t *testing.T) {
+	global := testGlobalConfiguration(t)
+	global.MysqldumpExecutable = fakeExecutable(t)
+	for _, path := range []string{global.Path, global.TmpPath} {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	latest := filepath.Join(global.Path, "mysql", PeriodLatest)
+
+	//exit status of mysqldump is not hidden by gzip in pipeline
+	t.Setenv("BOX_TEST_EXIT", "2")
+	dumper, err := NewMysql(global, Configuration{Name: "mysql", Type: TypeMysql, Latest: true, ForceLatest: true, Vars: map[string]string{"database": "db"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dumper.Dump(context.Background()); err == nil || !strings.Contains(err.Error(), "exit status 2") {
+		t.Errorf("Dump() error = %v, want exit status 2", err)
+	}
+	if _, err := os.Stat(latest); err == nil {
+		t.Error("failed dump was stored")
+	}
+
+	t.Setenv("BOX_TEST_EXIT", "0")
+	if err := dumper.Dump(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if content := readGzip(t, latest); content != "--verbose db\n" {
+		t.Errorf("dump content = %q", content)
+	}
+}
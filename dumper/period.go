@@ -4,8 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"regexp"
 	"sort"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -15,13 +16,128 @@ type PeriodDump struct {
 	dumpType            Type
 	rootPath            string
 	fileName            string
+	sourceFileName      string
+	storage             StorageMode
 	tmpDumpFileName     string
 	tmpLogFileName      string
 	tmpChecksumFileName string
-	maxItemsCount       int
+	kind                *periodKind
+	time                time.Time
+	retention           Retention
+	enabled             bool
 	overwrite           bool
 }
 
+// periodKind describes file naming scheme of period directory
+type periodKind struct {
+	directory string
+	layout    string
+	//start returns beginning of period containing t
+	start func(t time.Time) time.Time
+}
+
+var (
+	periodHourly = &periodKind{
+		directory: "hourly",
+		layout:    "2006-01-02T15",
+		start: func(t time.Time) time.Time {
+			//not time.Date, it is ambiguous in the hour repeated when daylight saving time ends
+			return t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+		},
+	}
+	periodDaily = &periodKind{
+		directory: "daily",
+		layout:    "2006-01-02",
+		start: func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		},
+	}
+	periodWeekly = &periodKind{
+		directory: "weekly",
+		start: func(t time.Time) time.Time {
+			day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+			return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		},
+	}
+	periodMonthly = &periodKind{
+		directory: "monthly",
+		layout:    "2006-01",
+		start: func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		},
+	}
+	periodYearly = &periodKind{
+		directory: "yearly",
+		layout:    "2006",
+		start: func(t time.Time) time.Time {
+			return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+		},
+	}
+)
+
+// periodKinds lists dated periods, from shortest to longest
+var periodKinds = []*periodKind{periodHourly, periodDaily, periodWeekly, periodMonthly, periodYearly}
+
+// nextStart returns beginning of period following the one containing t
+func (kind *periodKind) nextStart(t time.Time) time.Time {
+	start := kind.start(t)
+	switch kind {
+	case periodHourly:
+		return start.Add(time.Hour)
+	case periodDaily:
+		return start.AddDate(0, 0, 1)
+	case periodWeekly:
+		return start.AddDate(0, 0, 7)
+	case periodMonthly:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(1, 0, 0)
+	}
+}
+
+func (kind *periodKind) fileName(t time.Time) string {
+	if len(kind.layout) == 0 {
+		//ISO week, e.g. 2026-42
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%04d-%02d", year, week)
+	}
+	return t.Format(kind.layout)
+}
+
+// parse returns beginning of period encoded in file name
+func (kind *periodKind) parse(name string) (time.Time, bool) {
+	var t time.Time
+
+	if len(kind.layout) == 0 {
+		var year, week int
+		if _, err := fmt.Sscanf(name, "%04d-%02d", &year, &week); err != nil {
+			return t, false
+		}
+		//January 4th is always in the first ISO week
+		t = kind.start(time.Date(year, time.January, 4, 0, 0, 0, 0, time.Local)).AddDate(0, 0, 7*(week-1))
+	} else {
+		parsed, err := time.ParseInLocation(kind.layout, name, time.Local)
+		if err != nil {
+			return t, false
+		}
+		t = kind.start(parsed)
+	}
+
+	//reject anything that does not round trip, e.g. logs, checksums or foreign files
+	if kind.fileName(t) != name {
+		return t, false
+	}
+
+	return t, true
+}
+
+func (period *PeriodDump) periodName() string {
+	if period.kind == nil {
+		return PeriodLatest
+	}
+	return period.kind.directory
+}
+
 func (period *PeriodDump) dumpFileName() string {
 	return fmt.Sprintf("%s%c%s", period.rootPath, os.PathSeparator, period.fileName)
 }
@@ -39,53 +155,73 @@ func (period *PeriodDump) exists() bool {
 	return err == nil || !errors.Is(err, os.ErrNotExist)
 }
 
-func (period *PeriodDump) rotate() error {
-	if period.maxItemsCount < 0 {
-		return nil
+// expired returns names of dump files older than retention, files not matching naming scheme are ignored
+func (period *PeriodDump) expired() ([]string, error) {
+	if period.kind == nil || period.retention.Forever() {
+		return nil, nil
 	}
 
 	files, err := os.ReadDir(period.rootPath)
 	if err != nil {
-		return err
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
 	}
 
-	logFileRegexp, err := regexp.Compile("^.+\\.log$")
-	if err != nil {
-		return err
-	}
-	checksumFileRegexp, err := regexp.Compile("^.+\\.checksum$")
-	if err != nil {
-		return err
-	}
+	//retention counts from the start of current period, e.g. 1mo on October 31st keeps October
+	cutoff := period.kind.start(period.retention.cutoff(period.kind.start(period.time)))
 
 	var dumpFiles []string
 
 	for _, file := range files {
-		filename := file.Name()
-		if logFileRegexp.MatchString(filename) || checksumFileRegexp.MatchString(filename) {
+		if file.IsDir() {
 			continue
 		}
-		dumpFiles = append(dumpFiles, filename)
+		fileTime, ok := period.kind.parse(file.Name())
+		if !ok {
+			continue
+		}
+		if fileTime.After(cutoff) {
+			continue
+		}
+		dumpFiles = append(dumpFiles, file.Name())
 	}
 
 	sort.Strings(dumpFiles)
 
-	for i := 0; i < len(dumpFiles)-period.maxItemsCount; i++ {
-		dumpFilePath := fmt.Sprintf("%s%c%s", period.rootPath, os.PathSeparator, dumpFiles[i])
+	return dumpFiles, nil
+}
+
+// rotate deletes expired dump files, returns paths of deleted dump files
+func (period *PeriodDump) rotate() ([]string, error) {
+	dumpFiles, err := period.expired()
+	if err != nil {
+		return nil, err
+	}
+
+	var rotated []string
+
+	for _, dumpFile := range dumpFiles {
+		log.Infof("%s (%s) %s: expired, deleting", period.name, period.dumpType, dumpFile)
+
+		dumpFilePath := fmt.Sprintf("%s%c%s", period.rootPath, os.PathSeparator, dumpFile)
 		if err := os.Remove(dumpFilePath); err != nil {
-			log.Errorf("%s (%s) %s: unable to delete dump file: %s", period.name, period.dumpType, period.fileName, err)
+			log.Errorf("%s (%s) %s: unable to delete dump file: %s", period.name, period.dumpType, dumpFile, err)
+		} else {
+			rotated = append(rotated, dumpFilePath)
 		}
-		dumpChecksumPath := fmt.Sprintf("%s%c%s.checksum", period.rootPath, os.PathSeparator, dumpFiles[i])
+		dumpChecksumPath := fmt.Sprintf("%s%c%s.checksum", period.rootPath, os.PathSeparator, dumpFile)
 		if err := os.Remove(dumpChecksumPath); err != nil {
-			log.Errorf("%s (%s) %s: unable to delete checksum file: %s", period.name, period.dumpType, period.fileName, err)
+			log.Errorf("%s (%s) %s: unable to delete checksum file: %s", period.name, period.dumpType, dumpFile, err)
 		}
-		dumpLogPath := fmt.Sprintf("%s%c%s.log", period.rootPath, os.PathSeparator, dumpFiles[i])
+		dumpLogPath := fmt.Sprintf("%s%c%s.log", period.rootPath, os.PathSeparator, dumpFile)
 		if err := os.Remove(dumpLogPath); err != nil {
-			log.Errorf("%s (%s) %s: unable to delete log file: %s", period.name, period.dumpType, period.fileName, err)
+			log.Errorf("%s (%s) %s: unable to delete log file: %s", period.name, period.dumpType, dumpFile, err)
 		}
 	}
 
-	return nil
+	return rotated, nil
 }
 
 func (period *PeriodDump) execute() error {
@@ -97,21 +233,136 @@ func (period *PeriodDump) execute() error {
 	if err := makeDirectory(period.rootPath); err != nil {
 		return err
 	}
-	if err := copyFile(period.tmpDumpFileName, period.dumpFileName()); err != nil {
+
+	//all files are written to staging names first,
+	//dump file is renamed last, so exists() sees only complete periods
+	stagingDumpFileName := stagingFileName(period.dumpFileName())
+	stagingLogFileName := stagingFileName(period.logFileName())
+	stagingChecksumFileName := stagingFileName(period.checksumFileName())
+
+	defer func() {
+		for _, fileName := range []string{stagingDumpFileName, stagingLogFileName, stagingChecksumFileName} {
+			if err := removeIfExists(fileName); err != nil {
+				log.Errorf("%s (%s) %s: unable to delete staging file: %s", period.name, period.dumpType, period.fileName, err)
+			}
+		}
+	}()
+
+	if err := storeFile(period.storage, period.sourceFileName, stagingDumpFileName); err != nil {
+		return err
+	}
+	if err := copyFile(period.tmpLogFileName, stagingLogFileName); err != nil {
+		return err
+	}
+	if err := copyFile(period.tmpChecksumFileName, stagingChecksumFileName); err != nil {
+		return err
+	}
+
+	//replaced files are moved aside first, dump first, so sweep after crash restores them
+	//while the new dump file is missing and dump, log and checksum are always of the same dump
+	replaced := period.exists()
+	if replaced {
+		if err := period.moveAside(); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(stagingChecksumFileName, period.checksumFileName()); err != nil {
 		return err
 	}
-	if err := copyFile(period.tmpLogFileName, period.logFileName()); err != nil {
+	if err := os.Rename(stagingLogFileName, period.logFileName()); err != nil {
 		return err
 	}
-	if err := copyFile(period.tmpChecksumFileName, period.checksumFileName()); err != nil {
+	if err := os.Rename(stagingDumpFileName, period.dumpFileName()); err != nil {
 		return err
 	}
+	if err := syncDirectory(period.rootPath); err != nil {
+		return err
+	}
+
+	if replaced {
+		for _, fileName := range []string{period.dumpFileName(), period.logFileName(), period.checksumFileName()} {
+			if err := removeIfExists(previousFileName(fileName)); err != nil {
+				log.Errorf("%s (%s) %s: unable to delete replaced file: %s", period.name, period.dumpType, period.fileName, err)
+			}
+		}
+	}
 
 	log.Infof("%s (%s) %s: done", period.name, period.dumpType, period.fileName)
 
 	return nil
 }
 
+// moveAside renames dump, log and checksum files to previous file names
+func (period *PeriodDump) moveAside() error {
+	for _, fileName := range []string{period.dumpFileName(), period.logFileName(), period.checksumFileName()} {
+		if err := os.Rename(fileName, previousFileName(fileName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return syncDirectory(period.rootPath)
+}
+
+// sweep removes staging files left by interrupted runs, files replaced by interrupted commit
+// are restored when the new dump file is missing and removed otherwise
+func (period *PeriodDump) sweep() error {
+	files, err := os.ReadDir(period.rootPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	path := func(name string) string {
+		return fmt.Sprintf("%s%c%s", period.rootPath, os.PathSeparator, name)
+	}
+
+	//dump files are checked before anything is restored, dump file is restored with its log and checksum
+	committed := make(map[string]bool)
+	for _, file := range files {
+		target, ok := previousTarget(file.Name())
+		if file.IsDir() || !ok {
+			continue
+		}
+		dumpFile := strings.TrimSuffix(strings.TrimSuffix(target, ".log"), ".checksum")
+		if _, known := committed[dumpFile]; !known {
+			_, err := os.Stat(path(dumpFile))
+			committed[dumpFile] = err == nil
+		}
+	}
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		if isStagingFileName(file.Name()) {
+			log.Warnf("%s (%s) removing stale staging file %s", period.name, period.dumpType, file.Name())
+			if err := os.Remove(path(file.Name())); err != nil {
+				return err
+			}
+			continue
+		}
+		target, ok := previousTarget(file.Name())
+		if !ok {
+			continue
+		}
+		if committed[strings.TrimSuffix(strings.TrimSuffix(target, ".log"), ".checksum")] {
+			log.Warnf("%s (%s) removing replaced file %s", period.name, period.dumpType, file.Name())
+			if err := os.Remove(path(file.Name())); err != nil {
+				return err
+			}
+			continue
+		}
+		log.Warnf("%s (%s) restoring %s replaced by interrupted run", period.name, period.dumpType, target)
+		if err := os.Rename(path(file.Name()), path(target)); err != nil {
+			return err
+		}
+	}
+
+	return syncDirectory(period.rootPath)
+}
+
 func (period *PeriodDump) remove() error {
 	if !period.exists() {
 		return nil
@@ -0,0 +1,114 @@
+package dumper
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFiles writes files of directory, file name to content
+func writeFiles(t *testing.T, directory string, files map[string]string) {
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(directory, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFiles returns files of directory, file name to content
+func readFiles(t *testing.T, directory string) map[string]string {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(directory, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[entry.Name()] = string(content)
+	}
+	return files
+}
+
+func TestPeriodDump_executeOverwrite(t *testing.T) {
+	directory := t.TempDir()
+	tmp := t.TempDir()
+	writeFiles(t, directory, map[string]string{"latest": "old", "latest.log": "old log", "latest.checksum": "old checksum"})
+	writeFiles(t, tmp, map[string]string{"dump": "new", "dump.log": "new log", "dump.checksum": "new checksum"})
+
+	period := PeriodDump{
+		rootPath:            directory,
+		fileName:            "latest",
+		sourceFileName:      filepath.Join(tmp, "dump"),
+		storage:             StorageCopy,
+		tmpDumpFileName:     filepath.Join(tmp, "dump"),
+		tmpLogFileName:      filepath.Join(tmp, "dump.log"),
+		tmpChecksumFileName: filepath.Join(tmp, "dump.checksum"),
+		enabled:             true,
+		overwrite:           true,
+	}
+	if err := period.execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"latest": "new", "latest.log": "new log", "latest.checksum": "new checksum"}
+	if got := readFiles(t, directory); !reflect.DeepEqual(got, want) {
+		t.Errorf("execute() files = %v, want %v", got, want)
+	}
+}
+
+func TestPeriodDump_sweep(t *testing.T) {
+	old := map[string]string{"latest": "old", "latest.log": "old log", "latest.checksum": "old checksum"}
+	updated := map[string]string{"latest": "new", "latest.log": "new log", "latest.checksum": "new checksum"}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  map[string]string
+	}{
+		{
+			name:  "interrupted before dump was moved aside",
+			files: map[string]string{"latest": "old", "latest.log": "old log", "latest.checksum": "old checksum", ".latest.tmp": "new"},
+			want:  old,
+		},
+		{
+			name:  "interrupted while moving aside",
+			files: map[string]string{".latest.previous": "old", "latest.log": "old log", "latest.checksum": "old checksum", ".latest.tmp": "new"},
+			want:  old,
+		},
+		{
+			name: "interrupted before dump was committed",
+			files: map[string]string{
+				".latest.previous": "old", ".latest.log.previous": "old log", ".latest.checksum.previous": "old checksum",
+				"latest.checksum": "new checksum", "latest.log": "new log", ".latest.tmp": "new",
+			},
+			want: old,
+		},
+		{
+			name: "interrupted before replaced files were removed",
+			files: map[string]string{
+				"latest": "new", "latest.log": "new log", "latest.checksum": "new checksum",
+				".latest.log.previous": "old log", ".latest.checksum.previous": "old checksum",
+			},
+			want: updated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directory := t.TempDir()
+			writeFiles(t, directory, tt.files)
+
+			period := PeriodDump{rootPath: directory, fileName: "latest"}
+			if err := period.sweep(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := readFiles(t, directory); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sweep() files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package dumper
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -27,7 +28,7 @@ func NewPostgres(global GlobalConfiguration, local Configuration) (*PostgresDump
 	return &dumper, nil
 }
 
-func (dumper *PostgresDumper) Dump() error {
+func (dumper *PostgresDumper) Dump(ctx context.Context) error {
 	sb := strings.Builder{}
 
 	//https://www.postgresql.org/docs/14/app-pgdump.html
@@ -57,7 +58,7 @@ func (dumper *PostgresDumper) Dump() error {
 		sb.WriteString(" ")
 	}
 
-	sb.WriteString(fmt.Sprintf("| gzip > \"%s\"", esc(dumper.tmpDumpFileName())))
+	commandline := pipeline(sb.String(), fmt.Sprintf("gzip > \"%s\"", esc(dumper.tmpDumpFileName())))
 
-	return dumper.execute(sb.String())
+	return dumper.execute(ctx, commandline)
 }
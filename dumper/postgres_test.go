@@ -0,0 +1,80 @@
+package dumper
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeExecutable writes shell script printing its arguments and exiting with BOX_TEST_EXIT
+func fakeExecutable(t *testing.T) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake dump executable is shell script")
+	}
+	fileName := filepath.Join(t.TempDir(), "dump")
+	if err := os.WriteFile(fileName, []byte("#!/bin/sh\necho \"$@\"\nexit ${BOX_TEST_EXIT:-0}\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+// readGzip returns content of gzipped dump file
+func readGzip(t *testing.T, fileName string) string {
+	file, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// testGlobalConfiguration stores dumps in temporary directory
+func testGlobalConfiguration(t *testing.T) GlobalConfiguration {
+	directory := t.TempDir()
+	return GlobalConfiguration{ShExecutable: "sh", Path: filepath.Join(directory, "dumps"), TmpPath: filepath.Join(directory, "tmp")}
+}
+
+func TestPostgresDumper_Dump(t *testing.T) {
+	global := testGlobalConfiguration(t)
+	global.PgdumpExecutable = fakeExecutable(t)
+	for _, path := range []string{global.Path, global.TmpPath} {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	latest := filepath.Join(global.Path, "pg", PeriodLatest)
+
+	//exit status of pg_dump is not hidden by gzip in pipeline
+	t.Setenv("BOX_TEST_EXIT", "3")
+	dumper, err := NewPostgres(global, Configuration{Name: "pg", Type: TypePostgres, Latest: true, ForceLatest: true, Vars: map[string]string{"dbname": "db"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dumper.Dump(context.Background()); err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("Dump() error = %v, want exit status 3", err)
+	}
+	if _, err := os.Stat(latest); err == nil {
+		t.Error("failed dump was stored")
+	}
+
+	t.Setenv("BOX_TEST_EXIT", "0")
+	if err := dumper.Dump(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if content := readGzip(t, latest); content != "--verbose --format=plain --dbname=db\n" {
+		t.Errorf("dump content = %q", content)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package dumper
+
+import "os/exec"
+
+// killProcessGroup is not supported, only the shell is killed on cancellation
+func killProcessGroup(cmd *exec.Cmd) {
+}
@@ -0,0 +1,62 @@
+//go:build unix
+
+package dumper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// processAlive returns false when process is gone or is a zombie nobody waits for
+func processAlive(pid int) bool {
+	if stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat")); err == nil {
+		//state follows command name in parentheses
+		fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+		return len(fields) == 0 || fields[0] != "Z"
+	}
+	return syscall.Kill(pid, 0) == nil
+}
+
+func TestKillProcessGroup(t *testing.T) {
+	pidFileName := filepath.Join(t.TempDir(), "pid")
+	t.Setenv("PID_FILE", pidFileName)
+
+	dumper := &AbstractDumper{
+		globalConfiguration: GlobalConfiguration{ShExecutable: "sh", TmpPath: t.TempDir()},
+		configuration:       Configuration{Name: "db", Timeout: 200 * time.Millisecond},
+	}
+
+	ctx, cancel := dumper.withTimeout(context.Background())
+	defer cancel()
+
+	//sleep is grandchild of sh, started by subshell of pipeline
+	start := time.Now()
+	err := dumper.executeCommand(ctx, `( sleep 30 & echo $! > "$PID_FILE"; wait ) | cat`, 1)
+	if err == nil || err.Error() != "dump timed out: context deadline exceeded" {
+		t.Errorf("executeCommand() error = %v, want dump timed out", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("executeCommand() returned after %s, pipeline was not killed", elapsed)
+	}
+
+	content, err := os.ReadFile(pidFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); processAlive(pid); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			_ = syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("sleep %d outlived killed dump command", pid)
+		}
+	}
+}
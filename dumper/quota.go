@@ -0,0 +1,325 @@
+package dumper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// extra space reserved over the previous dump size, dumps tend to grow
+const sizeEstimateMargin = 1.1
+
+// Size is amount of bytes, configured as 1024, 500MB, 50GB, 2TB
+type Size int64
+
+func ParseSize(s string) (Size, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"TB", 1 << 40},
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size: %s, expected number with B, KB, MB, GB or TB suffix", s)
+	}
+
+	return Size(value * float64(multiplier)), nil
+}
+
+func (size *Size) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	parsed, err := ParseSize(s)
+	if err != nil {
+		return err
+	}
+	*size = parsed
+	return nil
+}
+
+func (size Size) String() string {
+	return formatFileSize(int64(size))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// diskUsage returns size of all files under paths, hardlinked files are counted once
+func diskUsage(paths ...string) (int64, error) {
+	type inode struct {
+		device uint64
+		inode  uint64
+	}
+	seen := make(map[inode]bool)
+
+	var total int64
+
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			if device, ino, ok := fileID(info); ok {
+				id := inode{device, ino}
+				if seen[id] {
+					return nil
+				}
+				seen[id] = true
+			}
+			total += info.Size()
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return total, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// previousDumpSize returns size of the most recent stored dump, 0 when there are no dumps
+func (dumper *AbstractDumper) previousDumpSize() (int64, error) {
+	if info, err := os.Stat(dumper.latest.dumpFileName()); err == nil {
+		return info.Size(), nil
+	}
+
+	artifacts, err := collectArtifacts(dumper.rootPath())
+	if err != nil {
+		return 0, err
+	}
+	if len(artifacts) == 0 {
+		return 0, nil
+	}
+
+	sort.Slice(artifacts, func(i, j int) bool {
+		return artifacts[i].Time.After(artifacts[j].Time)
+	})
+
+	return artifacts[0].Size, nil
+}
+
+// storedSize returns how much new dump will take in dump path
+func (dumper *AbstractDumper) storedSize(size int64) int64 {
+	if dumper.storageMode() != StorageCopy {
+		return size
+	}
+	var count int64
+	for _, period := range dumper.periods() {
+		if period.enabled && (period.overwrite || !period.exists()) {
+			count++
+		}
+	}
+	return size * count
+}
+
+// checkFreeSpace estimates dump size from the previous one and compares it with free space
+func (dumper *AbstractDumper) checkFreeSpace() error {
+	previousSize, err := dumper.previousDumpSize()
+	if err != nil {
+		return err
+	}
+	if previousSize == 0 {
+		log.Infof("%s (%s) no previous dump, free space check skipped", dumper.configuration.Name, dumper.configuration.Type)
+		return nil
+	}
+
+	estimate := int64(float64(previousSize) * sizeEstimateMargin)
+	tmpNeeded := estimate
+	rootNeeded := dumper.storedSize(estimate)
+
+	tmpFree, tmpDevice, err := freeSpace(existingParent(dumper.tmpPath()))
+	if err != nil {
+		log.Warnf("%s (%s) unable to check free space: %s", dumper.configuration.Name, dumper.configuration.Type, err)
+		return nil
+	}
+	rootFree, rootDevice, err := freeSpace(existingParent(dumper.rootPath()))
+	if err != nil {
+		log.Warnf("%s (%s) unable to check free space: %s", dumper.configuration.Name, dumper.configuration.Type, err)
+		return nil
+	}
+
+	if tmpDevice == rootDevice {
+		tmpNeeded += rootNeeded
+		rootNeeded = tmpNeeded
+	}
+
+	if uint64(tmpNeeded) > tmpFree {
+		return fmt.Errorf("not enough free space in tmp path %s: need %s, free %s",
+			dumper.tmpPath(), formatFileSize(tmpNeeded), formatFileSize(int64(tmpFree)))
+	}
+	if uint64(rootNeeded) > rootFree {
+		return fmt.Errorf("not enough free space in dump path %s: need %s, free %s",
+			dumper.rootPath(), formatFileSize(rootNeeded), formatFileSize(int64(rootFree)))
+	}
+
+	log.Infof("%s (%s) free space check passed: need %s", dumper.configuration.Name, dumper.configuration.Type, formatFileSize(tmpNeeded))
+
+	return nil
+}
+
+// enforceQuotas prunes the oldest dumps until new dump of size fits into per-dump and global quotas
+func (dumper *AbstractDumper) enforceQuotas(size int64) error {
+	needed := dumper.storedSize(size)
+
+	switch dumper.storageMode() {
+	case StorageHardlink:
+		if _, err := os.Stat(dumper.objectFileName(dumper.checksum)); err == nil {
+			needed = 0
+		}
+	case StorageCopy:
+		//latest dump is replaced, its space is reused
+		if info, err := os.Stat(dumper.latest.dumpFileName()); err == nil && dumper.latest.enabled {
+			needed -= info.Size()
+		}
+	}
+
+	if needed < 0 {
+		needed = 0
+	}
+
+	if quota := int64(dumper.configuration.MaxTotalSize); quota > 0 {
+		roots := []string{dumper.rootPath()}
+		if err := dumper.prune("max-total-size", quota, needed, roots, roots); err != nil {
+			return err
+		}
+	}
+
+	if quota := int64(dumper.globalConfiguration.MaxTotalSize); quota > 0 {
+		roots, prunable, unlock := dumper.lockConfiguredDumps()
+		defer unlock()
+		if err := dumper.prune("global max-total-size", quota, needed, roots, prunable); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lockConfiguredDumps returns root paths of the current dump and other configured dumps
+// and those of them, which files can be pruned: the current dump and other dumps locked for pruning,
+// running dumps are only counted
+func (dumper *AbstractDumper) lockConfiguredDumps() ([]string, []string, func()) {
+	roots := []string{dumper.rootPath()}
+	prunable := []string{dumper.rootPath()}
+	var locks []*Lock
+
+	for _, configuration := range dumper.globalConfiguration.Dumps {
+		other := &AbstractDumper{globalConfiguration: dumper.globalConfiguration, configuration: configuration}
+		root := other.rootPath()
+		if len(root) == 0 || filepath.Clean(root) == filepath.Clean(dumper.rootPath()) {
+			continue
+		}
+		roots = append(roots, root)
+
+		lock, err := AcquireLock(context.Background(), other.lockFileName(), true, 0)
+		if err != nil {
+			log.Warnf("%s (%s) files of %s are not pruned: %s", dumper.configuration.Name, dumper.configuration.Type, configuration.Name, err)
+			continue
+		}
+		locks = append(locks, lock)
+		prunable = append(prunable, root)
+	}
+
+	return roots, prunable, func() {
+		for _, lock := range locks {
+			if err := lock.Release(); err != nil {
+				log.Errorf("%s (%s) unable to release lock: %s", dumper.configuration.Name, dumper.configuration.Type, err)
+			}
+		}
+	}
+}
+
+// prune removes the oldest dumps of prunable roots until usage of roots with needed size fits into quota
+func (dumper *AbstractDumper) prune(quotaName string, quota, needed int64, roots, prunable []string) error {
+	if needed > quota {
+		return fmt.Errorf("%s %s is less than dump size %s", quotaName, formatFileSize(quota), formatFileSize(needed))
+	}
+
+	var artifacts []Artifact
+	for _, root := range prunable {
+		rootArtifacts, err := collectArtifacts(root)
+		if err != nil {
+			return err
+		}
+		artifacts = append(artifacts, rootArtifacts...)
+	}
+
+	sort.SliceStable(artifacts, func(i, j int) bool {
+		return artifacts[i].Time.Before(artifacts[j].Time)
+	})
+
+	for {
+		usage, err := diskUsage(roots...)
+		if err != nil {
+			return err
+		}
+		if usage+needed <= quota {
+			return nil
+		}
+		if len(artifacts) == 0 {
+			return fmt.Errorf("%s %s exceeded: used %s, need %s, nothing left to prune",
+				quotaName, formatFileSize(quota), formatFileSize(usage), formatFileSize(needed))
+		}
+
+		oldest := artifacts[0]
+		artifacts = artifacts[1:]
+
+		log.Warnf("%s (%s) %s exceeded, pruning %s", dumper.configuration.Name, dumper.configuration.Type, quotaName, oldest.Path)
+
+		if err := removeArtifact(oldest); err != nil {
+			return fmt.Errorf("unable to prune %s: %s", oldest.Path, err)
+		}
+
+		dumper.report.Pruned = append(dumper.report.Pruned, oldest.Path)
+	}
+}
+
+func existingParent(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
@@ -0,0 +1,168 @@
+package dumper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Size
+		wantErr bool
+	}{
+		{value: "1024", want: 1024},
+		{value: "100B", want: 100},
+		{value: "1kb", want: 1 << 10},
+		{value: "500MB", want: 500 << 20},
+		{value: " 1.5 GB ", want: 3 << 29},
+		{value: "2TB", want: 2 << 40},
+		{value: "", wantErr: true},
+		{value: "-1GB", wantErr: true},
+		{value: "10XB", wantErr: true},
+		{value: "GB", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseSize(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// writeDumps writes period files of size 100 bytes, path relative to directory
+func writeDumps(t *testing.T, directory string, paths ...string) {
+	for _, path := range paths {
+		fileName := filepath.Join(directory, path)
+		if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fileName, make([]byte, 100), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func existing(directory string, paths ...string) []string {
+	var found []string
+	for _, path := range paths {
+		if _, err := os.Stat(filepath.Join(directory, path)); err == nil {
+			found = append(found, path)
+		}
+	}
+	return found
+}
+
+func TestAbstractDumper_prune(t *testing.T) {
+	directory := t.TempDir()
+	files := []string{"a/daily/2026-10-03", "b/daily/2026-10-02", "a/daily/2026-10-01", "a/monthly/2026-09", "c/daily/2026-01-01"}
+	writeDumps(t, directory, files...)
+
+	global := GlobalConfiguration{
+		Path:    directory,
+		TmpPath: filepath.Join(directory, "tmp"),
+		Dumps:   []Configuration{{Name: "a"}, {Name: "b"}},
+	}
+	dumper := &AbstractDumper{globalConfiguration: global, configuration: Configuration{Name: "a"}}
+
+	roots, prunable, unlock := dumper.lockConfiguredDumps()
+	unlock()
+	if want := []string{filepath.Join(directory, "a"), filepath.Join(directory, "b")}; !reflect.DeepEqual(roots, want) || !reflect.DeepEqual(prunable, want) {
+		t.Fatalf("lockConfiguredDumps() = %v, %v, want configured dumps %v", roots, prunable, want)
+	}
+
+	//400 bytes of a and b with 50 bytes needed fit into 300 after pruning the two oldest dumps
+	if err := dumper.prune("quota", 300, 50, roots, prunable); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := existing(directory, files...), []string{"a/daily/2026-10-03", "b/daily/2026-10-02", "c/daily/2026-01-01"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("prune() kept %v, want %v", got, want)
+	}
+	if len(dumper.report.Pruned) != 2 || !strings.HasSuffix(dumper.report.Pruned[0], "2026-09") {
+		t.Errorf("prune() pruned %v, the oldest first", dumper.report.Pruned)
+	}
+
+	if err := dumper.prune("quota", 100, 150, roots, prunable); err == nil {
+		t.Error("expected error for dump larger than quota")
+	}
+}
+
+func TestAbstractDumper_lockConfiguredDumps(t *testing.T) {
+	directory := t.TempDir()
+	writeDumps(t, directory, "a/daily/2026-10-03", "b/daily/2026-10-01")
+
+	global := GlobalConfiguration{
+		Path:    directory,
+		TmpPath: filepath.Join(directory, "tmp"),
+		Dumps:   []Configuration{{Name: "a"}, {Name: "b"}},
+	}
+	dumper := &AbstractDumper{globalConfiguration: global, configuration: Configuration{Name: "a"}}
+
+	//b is running
+	running := &AbstractDumper{globalConfiguration: global, configuration: Configuration{Name: "b"}}
+	lock, err := AcquireLock(context.Background(), running.lockFileName(), true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+
+	roots, prunable, unlock := dumper.lockConfiguredDumps()
+	defer unlock()
+	if len(roots) != 2 || !reflect.DeepEqual(prunable, []string{filepath.Join(directory, "a")}) {
+		t.Fatalf("lockConfiguredDumps() = %v, %v, want b counted only", roots, prunable)
+	}
+
+	if err := dumper.prune("quota", 50, 0, roots, prunable); err == nil {
+		t.Error("expected quota error, files of running dump are not pruned")
+	}
+	if got := existing(directory, "a/daily/2026-10-03", "b/daily/2026-10-01"); !reflect.DeepEqual(got, []string{"b/daily/2026-10-01"}) {
+		t.Errorf("prune() kept %v, want files of running dump", got)
+	}
+}
+
+func TestAbstractDumper_checkFreeSpace(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("free space check is not supported")
+	}
+	directory := t.TempDir()
+
+	dumper := &AbstractDumper{
+		globalConfiguration: GlobalConfiguration{Path: directory, TmpPath: filepath.Join(directory, "tmp")},
+		configuration:       Configuration{Name: "db", Latest: true},
+		time:                time.Now(),
+	}
+	dumper.preparePeriods()
+
+	//no previous dump
+	if err := dumper.checkFreeSpace(); err != nil {
+		t.Fatal(err)
+	}
+
+	writeDumps(t, directory, "db/latest")
+	if err := dumper.checkFreeSpace(); err != nil {
+		t.Fatal(err)
+	}
+
+	free, _, err := freeSpace(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//sparse file as large as free space, dump estimate is larger
+	if err := os.Truncate(filepath.Join(directory, "db", "latest"), int64(free)); err != nil {
+		t.Skipf("unable to make sparse file: %s", err)
+	}
+	if err := dumper.checkFreeSpace(); err == nil || !strings.Contains(err.Error(), "not enough free space") {
+		t.Errorf("checkFreeSpace() error = %v, want not enough free space", err)
+	}
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package dumper
+
+import (
+	"os"
+	"syscall"
+)
+
+// FICLONE ioctl request, see ioctl_ficlone(2)
+const ficlone = 0x40049409
+
+func reflinkFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, out.Fd(), ficlone, in.Fd())
+	if errno != 0 {
+		out.Close()
+		os.Remove(dest)
+		return errno
+	}
+
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package dumper
+
+import "errors"
+
+func reflinkFile(src, dest string) error {
+	return errors.New("reflink not supported")
+}
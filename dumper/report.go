@@ -0,0 +1,27 @@
+package dumper
+
+// Report describes the result of the last Dump call
+type Report struct {
+	//dump was made, false when all periods already exist
+	Dumped bool
+	//dump would be made, set by dry run instead of Dumped
+	Planned bool
+	//dump file size, 0 when no dump was made
+	Size int64
+	//SHA256 of dump file
+	Checksum string
+	//all checksums of dump file by hash type: md5, sha1, sha256
+	Checksums map[string]string
+	//dump files deleted to fit into max-total-size
+	Pruned []string
+	//period dump files written
+	Files []string
+	//expired period dump files deleted by retention
+	Rotated []string
+	//number of dump command executions, more than 1 when it was retried
+	Attempts int
+	//the last lines of dump command log
+	LogTail string
+	//errors of on-success and after hooks of stored dump, they do not fail the dump
+	HookErrors []string
+}
@@ -0,0 +1,123 @@
+package dumper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type retentionUnit string
+
+const (
+	retentionHour  retentionUnit = "h"
+	retentionDay   retentionUnit = "d"
+	retentionWeek  retentionUnit = "w"
+	retentionMonth retentionUnit = "mo"
+	retentionYear  retentionUnit = "y"
+)
+
+// Retention defines how long period dumps are kept, e.g. 48h, 14d, 8w, 12mo, 7y or forever
+type Retention struct {
+	value   int
+	unit    retentionUnit
+	forever bool
+}
+
+func ParseRetention(s string) (Retention, error) {
+	s = strings.TrimSpace(s)
+
+	if s == "forever" || s == "-1" {
+		return Retention{forever: true}, nil
+	}
+
+	//longest suffixes first, "mo" must not be read as "o"
+	for _, unit := range []retentionUnit{retentionMonth, retentionHour, retentionDay, retentionWeek, retentionYear} {
+		if !strings.HasSuffix(s, string(unit)) {
+			continue
+		}
+		value, err := strconv.Atoi(strings.TrimSuffix(s, string(unit)))
+		if err != nil || value < 0 {
+			return Retention{}, fmt.Errorf("invalid retention value: %s", s)
+		}
+		return Retention{value: value, unit: unit}, nil
+	}
+
+	return Retention{}, fmt.Errorf("invalid retention: %s, expected number with h, d, w, mo or y suffix, or forever", s)
+}
+
+func (r *Retention) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	retention, err := ParseRetention(s)
+	if err != nil {
+		return err
+	}
+	*r = retention
+	return nil
+}
+
+func (r Retention) MarshalYAML() (interface{}, error) {
+	return r.String(), nil
+}
+
+func (r Retention) String() string {
+	if r.forever {
+		return "forever"
+	}
+	if r.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%d%s", r.value, r.unit)
+}
+
+func (r Retention) IsZero() bool {
+	return !r.forever && len(r.unit) == 0
+}
+
+func (r Retention) Forever() bool {
+	return r.forever
+}
+
+// cutoff returns the moment before which dumps are expired
+func (r Retention) cutoff(now time.Time) time.Time {
+	switch r.unit {
+	case retentionHour:
+		return now.Add(-time.Duration(r.value) * time.Hour)
+	case retentionDay:
+		return now.AddDate(0, 0, -r.value)
+	case retentionWeek:
+		return now.AddDate(0, 0, -7*r.value)
+	case retentionMonth:
+		return addMonths(now, -r.value)
+	case retentionYear:
+		return addMonths(now, -12*r.value)
+	default:
+		return now
+	}
+}
+
+// addMonths moves t by months, day of month is clamped to the end of target month instead of overflowing into the next one
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, t.Location())
+	day := t.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// retentionOf returns explicit retention or builds one from legacy items count
+func retentionOf(keep Retention, count int, unit retentionUnit) Retention {
+	if !keep.IsZero() {
+		return keep
+	}
+	if count < 0 {
+		return Retention{forever: true}
+	}
+	return Retention{value: count, unit: unit}
+}
@@ -0,0 +1,245 @@
+package dumper
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRetention(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Retention
+		wantErr bool
+	}{
+		{name: "hours", value: "48h", want: Retention{value: 48, unit: retentionHour}},
+		{name: "days", value: "14d", want: Retention{value: 14, unit: retentionDay}},
+		{name: "weeks", value: "8w", want: Retention{value: 8, unit: retentionWeek}},
+		{name: "months", value: "12mo", want: Retention{value: 12, unit: retentionMonth}},
+		{name: "years", value: "7y", want: Retention{value: 7, unit: retentionYear}},
+		{name: "forever", value: "forever", want: Retention{forever: true}},
+		{name: "minus one", value: "-1", want: Retention{forever: true}},
+		{name: "no unit", value: "14", wantErr: true},
+		{name: "negative", value: "-2d", wantErr: true},
+		{name: "unknown unit", value: "3m", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRetention(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRetention() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRetention() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodKind_parse(t *testing.T) {
+	tests := []struct {
+		name   string
+		kind   *periodKind
+		file   string
+		want   time.Time
+		wantOk bool
+	}{
+		{name: "hourly", kind: periodHourly, file: "2026-10-17T09", want: time.Date(2026, 10, 17, 9, 0, 0, 0, time.Local), wantOk: true},
+		{name: "daily", kind: periodDaily, file: "2026-10-17", want: time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local), wantOk: true},
+		{name: "daily log", kind: periodDaily, file: "2026-10-17.log"},
+		{name: "daily foreign", kind: periodDaily, file: "README"},
+		{name: "weekly", kind: periodWeekly, file: "2026-42", want: time.Date(2026, 10, 12, 0, 0, 0, 0, time.Local), wantOk: true},
+		{name: "weekly first", kind: periodWeekly, file: "2026-01", want: time.Date(2025, 12, 29, 0, 0, 0, 0, time.Local), wantOk: true},
+		{name: "weekly out of range", kind: periodWeekly, file: "2026-54"},
+		{name: "monthly", kind: periodMonthly, file: "2026-02", want: time.Date(2026, 2, 1, 0, 0, 0, 0, time.Local), wantOk: true},
+		{name: "monthly checksum", kind: periodMonthly, file: "2026-02.checksum"},
+		{name: "yearly", kind: periodYearly, file: "2019", want: time.Date(2019, 1, 1, 0, 0, 0, 0, time.Local), wantOk: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.kind.parse(tt.file)
+			if ok != tt.wantOk {
+				t.Fatalf("parse() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if ok && !got.Equal(tt.want) {
+				t.Errorf("parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodKind_nextStart(t *testing.T) {
+	now := time.Date(2026, 12, 31, 15, 30, 0, 0, time.Local)
+	tests := []struct {
+		name string
+		kind *periodKind
+		want time.Time
+	}{
+		{name: "hourly", kind: periodHourly, want: time.Date(2026, 12, 31, 16, 0, 0, 0, time.Local)},
+		{name: "daily", kind: periodDaily, want: time.Date(2027, 1, 1, 0, 0, 0, 0, time.Local)},
+		{name: "weekly", kind: periodWeekly, want: time.Date(2027, 1, 4, 0, 0, 0, 0, time.Local)},
+		{name: "monthly", kind: periodMonthly, want: time.Date(2027, 1, 1, 0, 0, 0, 0, time.Local)},
+		{name: "yearly", kind: periodYearly, want: time.Date(2027, 1, 1, 0, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.kind.nextStart(now); !got.Equal(tt.want) {
+				t.Errorf("nextStart() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodDump_expired(t *testing.T) {
+	tests := []struct {
+		name      string
+		kind      *periodKind
+		now       time.Time
+		retention Retention
+		files     []string
+		want      []string
+	}{
+		{
+			name:      "hourly",
+			kind:      periodHourly,
+			now:       time.Date(2026, 10, 19, 9, 30, 0, 0, time.Local),
+			retention: Retention{value: 48, unit: retentionHour},
+			files:     []string{"2026-10-17T08", "2026-10-17T09", "2026-10-17T09.log", "2026-10-17T10", "2026-10-19T09", "2026-10-19"},
+			want:      []string{"2026-10-17T08", "2026-10-17T09"},
+		}, {
+			name:      "daily",
+			kind:      periodDaily,
+			now:       time.Date(2026, 10, 19, 3, 0, 0, 0, time.Local),
+			retention: Retention{value: 14, unit: retentionDay},
+			files:     []string{"2026-10-01", "2026-10-01.log", "2026-10-05", "2026-10-06", "2026-10-19", "notes.txt"},
+			want:      []string{"2026-10-01", "2026-10-05"},
+		}, {
+			name:      "monthly at end of month",
+			kind:      periodMonthly,
+			now:       time.Date(2026, 10, 31, 3, 0, 0, 0, time.Local),
+			retention: Retention{value: 1, unit: retentionMonth},
+			files:     []string{"2026-08", "2026-09", "2026-10"},
+			want:      []string{"2026-08", "2026-09"},
+		}, {
+			name:      "monthly at end of month into shorter month",
+			kind:      periodMonthly,
+			now:       time.Date(2026, 5, 31, 3, 0, 0, 0, time.Local),
+			retention: Retention{value: 3, unit: retentionMonth},
+			files:     []string{"2026-01", "2026-02", "2026-03", "2026-04", "2026-05"},
+			want:      []string{"2026-01", "2026-02"},
+		}, {
+			name:      "daily with months at end of month",
+			kind:      periodDaily,
+			now:       time.Date(2026, 3, 31, 3, 0, 0, 0, time.Local),
+			retention: Retention{value: 1, unit: retentionMonth},
+			files:     []string{"2026-02-27", "2026-02-28", "2026-03-01", "2026-03-31"},
+			want:      []string{"2026-02-27", "2026-02-28"},
+		}, {
+			name:      "yearly on February 29th",
+			kind:      periodYearly,
+			now:       time.Date(2028, 2, 29, 3, 0, 0, 0, time.Local),
+			retention: Retention{value: 2, unit: retentionYear},
+			files:     []string{"2025", "2026", "2027", "2028"},
+			want:      []string{"2025", "2026"},
+		}, {
+			name:      "daily with years on February 29th",
+			kind:      periodDaily,
+			now:       time.Date(2028, 2, 29, 3, 0, 0, 0, time.Local),
+			retention: Retention{value: 1, unit: retentionYear},
+			files:     []string{"2027-02-27", "2027-02-28", "2027-03-01"},
+			want:      []string{"2027-02-27", "2027-02-28"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directory := t.TempDir()
+			for _, name := range tt.files {
+				if err := os.WriteFile(directory+string(os.PathSeparator)+name, []byte("dump"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			period := PeriodDump{
+				rootPath:  directory,
+				kind:      tt.kind,
+				time:      tt.now,
+				retention: tt.retention,
+			}
+
+			got, err := period.expired()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodKind_hourly(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone database not available: %s", err)
+	}
+	local := time.Local
+	time.Local = location
+	defer func() { time.Local = local }()
+
+	utc := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.UTC).In(location)
+	}
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		start     time.Time
+		nextStart time.Time
+		file      string
+		//start of period parsed from file name, the later one of repeated hour
+		parsed time.Time
+	}{
+		{name: "summer", now: utc(10, 19, 7, 30), start: utc(10, 19, 7, 0), nextStart: utc(10, 19, 8, 0), file: "2026-10-19T09", parsed: utc(10, 19, 7, 0)},
+		//clocks jump from 02:00 to 03:00
+		{name: "before DST start", now: utc(3, 29, 0, 30), start: utc(3, 29, 0, 0), nextStart: utc(3, 29, 1, 0), file: "2026-03-29T01", parsed: utc(3, 29, 0, 0)},
+		{name: "after DST start", now: utc(3, 29, 1, 30), start: utc(3, 29, 1, 0), nextStart: utc(3, 29, 2, 0), file: "2026-03-29T03", parsed: utc(3, 29, 1, 0)},
+		//clocks go back from 03:00 to 02:00, both hours are 2026-10-25T02
+		{name: "before DST end", now: utc(10, 25, 0, 30), start: utc(10, 25, 0, 0), nextStart: utc(10, 25, 1, 0), file: "2026-10-25T02", parsed: utc(10, 25, 1, 0)},
+		{name: "after DST end", now: utc(10, 25, 1, 30), start: utc(10, 25, 1, 0), nextStart: utc(10, 25, 2, 0), file: "2026-10-25T02", parsed: utc(10, 25, 1, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := periodHourly.start(tt.now); !got.Equal(tt.start) {
+				t.Errorf("start() = %v, want %v", got, tt.start)
+			}
+			if got := periodHourly.nextStart(tt.now); !got.Equal(tt.nextStart) {
+				t.Errorf("nextStart() = %v, want %v", got, tt.nextStart)
+			}
+			if got := periodHourly.fileName(tt.now); got != tt.file {
+				t.Errorf("fileName() = %s, want %s", got, tt.file)
+			}
+			if got, ok := periodHourly.parse(tt.file); !ok || !got.Equal(tt.parsed) {
+				t.Errorf("parse() = %v, %v, want %v", got, ok, tt.parsed)
+			}
+		})
+	}
+
+	//skipped hour is not a dump file name
+	if got, ok := periodHourly.parse("2026-03-29T02"); ok {
+		t.Errorf("parse() = %v, want skipped hour rejected", got)
+	}
+
+	//retention counts real hours, the repeated hour is kept until its later occurrence expires
+	directory := t.TempDir()
+	writeDumps(t, directory, "2026-10-25T01", "2026-10-25T02", "2026-10-25T03")
+	period := PeriodDump{rootPath: directory, kind: periodHourly, time: utc(10, 26, 0, 30), retention: Retention{value: 24, unit: retentionHour}}
+	if got, err := period.expired(); err != nil || !reflect.DeepEqual(got, []string{"2026-10-25T01"}) {
+		t.Errorf("expired() = %v, %v, want [2026-10-25T01]", got, err)
+	}
+	period.time = utc(10, 26, 1, 30)
+	if got, err := period.expired(); err != nil || !reflect.DeepEqual(got, []string{"2026-10-25T01", "2026-10-25T02"}) {
+		t.Errorf("expired() = %v, %v, want [2026-10-25T01 2026-10-25T02]", got, err)
+	}
+}
@@ -0,0 +1,63 @@
+package dumper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRetryDelay = 30 * time.Second
+	maxRetryDelay     = time.Hour
+)
+
+// retryAfter waits for delay between attempts
+var retryAfter = time.After
+
+// executeWithRetries executes dump command, failed attempts are retried with exponentially growing delay
+func (dumper *AbstractDumper) executeWithRetries(ctx context.Context, commandline string) error {
+	attempts := dumper.configuration.Retries + 1
+
+	delay := dumper.configuration.RetryDelay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+
+	for attempt := 1; ; attempt++ {
+		dumper.report.Attempts = attempt
+
+		err := dumper.executeCommand(ctx, commandline, attempt)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		if attempt >= attempts {
+			if attempts > 1 {
+				return fmt.Errorf("%w (failed %d attempts)", err, attempts)
+			}
+			return err
+		}
+
+		log.Warnf("%s (%s) attempt %d of %d failed: %s, retrying in %s",
+			dumper.configuration.Name, dumper.configuration.Type, attempt, attempts, err, delay)
+
+		if err := dumper.clearTmpDumpFiles(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return dumper.interrupted(ctx)
+		case <-retryAfter(delay):
+		}
+
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
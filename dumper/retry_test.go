@@ -0,0 +1,181 @@
+package dumper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_pipeline(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		filter   string
+		exitCode int
+	}{
+		{"success", "echo data", "cat > /dev/null", 0},
+		{"command failed", "echo data; exit 3", "cat > /dev/null", 3},
+		{"filter failed", "echo data", "cat > /dev/null; exit 4", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := exec.Command("sh", "-c", pipeline(tt.command, tt.filter)).Run()
+			exitCode := 0
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				exitCode = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if exitCode != tt.exitCode {
+				t.Errorf("exit code = %d, want %d", exitCode, tt.exitCode)
+			}
+		})
+	}
+}
+
+// newRetryDumper returns dumper running commandline in tmp path with retries, waits between attempts are recorded
+func newRetryDumper(t *testing.T, retries int, delay time.Duration) (*AbstractDumper, *[]time.Duration) {
+	var waits []time.Duration
+	after := retryAfter
+	retryAfter = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		return after(0)
+	}
+	t.Cleanup(func() {
+		retryAfter = after
+	})
+
+	dumper := &AbstractDumper{
+		globalConfiguration: GlobalConfiguration{ShExecutable: "sh", TmpPath: t.TempDir()},
+		configuration:       Configuration{Name: "db", Retries: retries, RetryDelay: delay},
+	}
+	t.Setenv("DUMP", dumper.tmpDumpFileName())
+	return dumper, &waits
+}
+
+func TestAbstractDumper_executeWithRetries(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("dump command is shell script")
+	}
+
+	tests := []struct {
+		name     string
+		retries  int
+		delay    time.Duration
+		command  string
+		attempts int
+		waits    []time.Duration
+		err      string
+	}{
+		{
+			name:     "success",
+			retries:  3,
+			command:  `echo data > "$DUMP"`,
+			attempts: 1,
+		}, {
+			name:     "no retries",
+			command:  "exit 3",
+			attempts: 1,
+			err:      "exit status 3",
+		}, {
+			name:     "default delay",
+			retries:  2,
+			command:  "exit 3",
+			attempts: 3,
+			waits:    []time.Duration{30 * time.Second, time.Minute},
+			err:      "exit status 3 (failed 3 attempts)",
+		}, {
+			name:     "delay capped",
+			retries:  4,
+			delay:    20 * time.Minute,
+			command:  "exit 3",
+			attempts: 5,
+			waits:    []time.Duration{20 * time.Minute, 40 * time.Minute, time.Hour, time.Hour},
+			err:      "exit status 3 (failed 5 attempts)",
+		}, {
+			name:     "succeeded after retry",
+			retries:  2,
+			command:  `if [ -f "$DUMP.failed" ]; then echo data > "$DUMP"; else touch "$DUMP.failed"; exit 1; fi`,
+			attempts: 2,
+			waits:    []time.Duration{30 * time.Second},
+		}, {
+			name:     "empty dump",
+			retries:  1,
+			command:  `touch "$DUMP"`,
+			attempts: 2,
+			waits:    []time.Duration{30 * time.Second},
+			err:      "empty dump file (failed 2 attempts)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dumper, waits := newRetryDumper(t, tt.retries, tt.delay)
+
+			err := dumper.executeWithRetries(context.Background(), tt.command)
+			if tt.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.err != "" && (err == nil || err.Error() != tt.err) {
+				t.Errorf("executeWithRetries() error = %v, want %s", err, tt.err)
+			}
+			if dumper.report.Attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", dumper.report.Attempts, tt.attempts)
+			}
+			if !reflect.DeepEqual(*waits, tt.waits) {
+				t.Errorf("waits = %v, want %v", *waits, tt.waits)
+			}
+		})
+	}
+}
+
+func TestAbstractDumper_executeWithRetriesClearsTmpFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("dump command is shell script")
+	}
+	dumper, _ := newRetryDumper(t, 2, 0)
+
+	//partial dump of failed attempt must not be appended to
+	command := `if [ -f "$DUMP" ]; then echo stale > "$DUMP.stale"; fi; echo partial >> "$DUMP"; exit 1`
+	if err := dumper.executeWithRetries(context.Background(), command); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := os.Stat(dumper.tmpDumpFileName() + ".stale"); err == nil {
+		t.Error("tmp dump file of failed attempt was not removed")
+	}
+
+	content, err := os.ReadFile(dumper.tmpLogFileName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(content), "=== attempt "); n != 3 {
+		t.Errorf("log has %d attempts, want 3:\n%s", n, content)
+	}
+}
+
+func TestAbstractDumper_executeWithRetriesInterrupted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("dump command is shell script")
+	}
+	dumper, _ := newRetryDumper(t, 2, 0)
+
+	//cancelled while waiting for the next attempt
+	ctx, cancel := context.WithCancel(context.Background())
+	retryAfter = func(time.Duration) <-chan time.Time {
+		cancel()
+		return nil
+	}
+
+	err := dumper.executeWithRetries(ctx, "exit 1")
+	if !errors.Is(err, context.Canceled) || err.Error() != "dump aborted: context canceled" {
+		t.Errorf("executeWithRetries() error = %v, want dump aborted", err)
+	}
+	if dumper.report.Attempts != 1 {
+		t.Errorf("attempts = %d, want 1", dumper.report.Attempts)
+	}
+}
@@ -0,0 +1,41 @@
+package dumper
+
+import (
+	"sort"
+	"strings"
+)
+
+const SecretMask = "******"
+
+// IsSecretKey reports whether configuration key names a secret value like password or token
+func IsSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range []string{"pass", "secret", "token", "key"} {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSecretReplacer masks secrets, also as they are escaped in command lines
+func NewSecretReplacer(secrets []string) *strings.Replacer {
+	var all []string
+	for _, secret := range secrets {
+		if len(secret) != 0 {
+			all = append(all, secret, esc(secret))
+		}
+	}
+
+	//longest first, so secrets containing other secrets are masked entirely
+	sort.Slice(all, func(i, j int) bool {
+		return len(all[i]) > len(all[j])
+	})
+
+	pairs := make([]string, 0, 2*len(all))
+	for _, secret := range all {
+		pairs = append(pairs, secret, SecretMask)
+	}
+
+	return strings.NewReplacer(pairs...)
+}
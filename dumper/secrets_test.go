@@ -0,0 +1,34 @@
+package dumper
+
+import "testing"
+
+func TestNewSecretReplacer(t *testing.T) {
+	replacer := NewSecretReplacer([]string{"pa$s", "pa$sword", "", "x1"})
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "password=pa$sword", want: "password=******"},
+		{value: `PGPASSWORD="pa\$sword" pg_dump`, want: `PGPASSWORD="******" pg_dump`},
+		{value: `-p "pa\$s" -u x1`, want: `-p "******" -u ******`},
+		{value: "nothing secret", want: "nothing secret"},
+	}
+	for _, tt := range tests {
+		if got := replacer.Replace(tt.value); got != tt.want {
+			t.Errorf("Replace(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAbstractDumper_maskSecrets(t *testing.T) {
+	dumper := &AbstractDumper{
+		globalConfiguration: GlobalConfiguration{Secrets: []string{"hunter2"}},
+		configuration:       Configuration{Vars: map[string]string{"host": "db", "password": "pw", "dbname": "app"}},
+	}
+
+	commandline := `PGPASSWORD="pw" pg_dump --host=db --dbname=app --sslpassword=hunter2`
+	if got, want := dumper.maskSecrets(commandline), `PGPASSWORD="******" pg_dump --host=db --dbname=app --sslpassword=******`; got != want {
+		t.Errorf("maskSecrets() = %s, want %s", got, want)
+	}
+}
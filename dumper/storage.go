@@ -0,0 +1,150 @@
+package dumper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type StorageMode string
+
+const (
+	//every period gets its own full copy of the dump
+	StorageCopy StorageMode = "copy"
+	//dump is stored once in the object store, periods are hardlinks to it
+	StorageHardlink StorageMode = "hardlink"
+	//periods are reflinks (copy-on-write clones) of the dump, falls back to copy
+	StorageReflink StorageMode = "reflink"
+)
+
+const objectsDirectoryName = "objects"
+
+// link and reflink make period files of stored dump, copy is the fallback when they fail
+var (
+	link    = os.Link
+	reflink = reflinkFile
+)
+
+func (dumper *AbstractDumper) storageMode() StorageMode {
+	if len(dumper.configuration.Storage) != 0 {
+		return dumper.configuration.Storage
+	}
+	if len(dumper.globalConfiguration.Storage) != 0 {
+		return dumper.globalConfiguration.Storage
+	}
+	return StorageCopy
+}
+
+func (dumper *AbstractDumper) objectsPath() string {
+	return fmt.Sprintf("%s%c%s", dumper.rootPath(), os.PathSeparator, objectsDirectoryName)
+}
+
+func (dumper *AbstractDumper) objectFileName(checksum string) string {
+	return fmt.Sprintf("%s%c%s", dumper.objectsPath(), os.PathSeparator, checksum)
+}
+
+// storeObject puts tmp dump file into content-addressed object store, returns object path
+func (dumper *AbstractDumper) storeObject() (string, error) {
+	if len(dumper.checksum) == 0 {
+		return "", errors.New("dump checksum not calculated")
+	}
+	if err := makeDirectory(dumper.objectsPath()); err != nil {
+		return "", err
+	}
+
+	objectFileName := dumper.objectFileName(dumper.checksum)
+
+	if _, err := os.Stat(objectFileName); err == nil {
+		log.Infof("%s (%s) object %s already stored", dumper.configuration.Name, dumper.configuration.Type, dumper.checksum)
+		return objectFileName, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	stagingObjectFileName := stagingFileName(objectFileName)
+	defer removeIfExists(stagingObjectFileName)
+
+	if err := copyFile(dumper.tmpDumpFileName(), stagingObjectFileName); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(stagingObjectFileName, 0444); err != nil {
+		return "", err
+	}
+	if err := os.Rename(stagingObjectFileName, objectFileName); err != nil {
+		return "", err
+	}
+	if err := syncDirectory(dumper.objectsPath()); err != nil {
+		return "", err
+	}
+
+	log.Infof("%s (%s) object %s stored", dumper.configuration.Name, dumper.configuration.Type, dumper.checksum)
+
+	return objectFileName, nil
+}
+
+// collectGarbage removes objects not referenced by any period
+func collectGarbage(objectsPath string) error {
+	files, err := os.ReadDir(objectsPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || isStagingFileName(file.Name()) {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			return err
+		}
+		count, ok := linkCount(info)
+		if !ok || count > 1 {
+			continue
+		}
+		objectFileName := fmt.Sprintf("%s%c%s", objectsPath, os.PathSeparator, file.Name())
+		if err := os.Remove(objectFileName); err != nil {
+			log.Errorf("unable to delete object %s: %s", objectFileName, err)
+			continue
+		}
+		log.Infof("object %s released", objectFileName)
+	}
+
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// storeFile puts src to dest according to storage mode
+func storeFile(mode StorageMode, src, dest string) error {
+	switch mode {
+	case StorageHardlink:
+		if err := removeIfExists(dest); err != nil {
+			return err
+		}
+		if err := link(src, dest); err != nil {
+			log.Warnf("hardlink %s -> %s failed, copying: %s", src, dest, err)
+			return copyFile(src, dest)
+		}
+		return nil
+	case StorageReflink:
+		if err := reflink(src, dest); err != nil {
+			log.Debugf("reflink %s -> %s failed, copying: %s", src, dest, err)
+			return copyFile(src, dest)
+		}
+		return nil
+	default:
+		return copyFile(src, dest)
+	}
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
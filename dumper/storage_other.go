@@ -0,0 +1,20 @@
+//go:build !unix
+
+package dumper
+
+import (
+	"errors"
+	"os"
+)
+
+func linkCount(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+func fileID(info os.FileInfo) (uint64, uint64, bool) {
+	return 0, 0, false
+}
+
+func freeSpace(path string) (uint64, uint64, error) {
+	return 0, 0, errors.New("free space check not supported")
+}
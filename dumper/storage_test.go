@@ -0,0 +1,139 @@
+package dumper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestAbstractDumper_storeObject(t *testing.T) {
+	global := testGlobalConfiguration(t)
+	global.PgdumpExecutable = fakeExecutable(t)
+	global.Storage = StorageHardlink
+	for _, path := range []string{global.Path, global.TmpPath} {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//identical dumps of two runs are stored once
+	var files []string
+	for run := 0; run < 2; run++ {
+		dumper, err := NewPostgres(global, Configuration{Name: "pg", Type: TypePostgres, Latest: true, ForceLatest: true, Daily: true, Days: 7})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dumper.Dump(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, dumper.Report().Files...)
+	}
+
+	objects, err := os.ReadDir(filepath.Join(global.Path, "pg", objectsDirectoryName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 {
+		t.Fatalf("expected 1 object, got %d", len(objects))
+	}
+	object, err := os.Stat(filepath.Join(global.Path, "pg", objectsDirectoryName, objects[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the first run writes latest and daily, the second one only latest
+	if len(files) != 3 {
+		t.Fatalf("expected 3 files written, got %v", files)
+	}
+	for _, fileName := range files {
+		info, err := os.Stat(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !os.SameFile(info, object) {
+			t.Errorf("%s is not hardlink of object %s", fileName, objects[0].Name())
+		}
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("link count is not supported")
+	}
+	directory := t.TempDir()
+	objectsPath := filepath.Join(directory, objectsDirectoryName)
+	if err := os.MkdirAll(filepath.Join(objectsPath, "directory"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	staged := stagingFileName("staged")
+	writeFiles(t, objectsPath, map[string]string{"released": "a", "referenced": "b", staged: "c"})
+	if err := os.Link(filepath.Join(objectsPath, "referenced"), filepath.Join(directory, "latest")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := collectGarbage(objectsPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := existing(objectsPath, "released", "referenced", staged, "directory"); !reflect.DeepEqual(got, []string{"referenced", staged, "directory"}) {
+		t.Errorf("collectGarbage() kept %v, want object of period, staging object and directory", got)
+	}
+
+	if err := collectGarbage(filepath.Join(directory, "missing")); err != nil {
+		t.Errorf("collectGarbage() of missing objects error = %v", err)
+	}
+}
+
+func TestStoreFile(t *testing.T) {
+	failing := func(src, dest string) error {
+		return errors.New("not supported")
+	}
+
+	tests := []struct {
+		name     string
+		mode     StorageMode
+		link     func(src, dest string) error
+		reflink  func(src, dest string) error
+		sameFile bool
+	}{
+		{name: "copy", mode: StorageCopy},
+		{name: "hardlink", mode: StorageHardlink, sameFile: true},
+		{name: "hardlink failed", mode: StorageHardlink, link: failing},
+		{name: "reflink failed", mode: StorageReflink, reflink: failing},
+		{name: "reflink", mode: StorageReflink},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(linkFile, reflinkFile func(src, dest string) error) {
+				link, reflink = linkFile, reflinkFile
+			}(link, reflink)
+			if tt.link != nil {
+				link = tt.link
+			}
+			if tt.reflink != nil {
+				reflink = tt.reflink
+			}
+
+			directory := t.TempDir()
+			writeFiles(t, directory, map[string]string{"object": "dump", "latest": "old dump"})
+			src, dest := filepath.Join(directory, "object"), filepath.Join(directory, "latest")
+
+			if err := storeFile(tt.mode, src, dest); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := readFiles(t, directory)["latest"]; got != "dump" {
+				t.Errorf("stored file = %q, want dump", got)
+			}
+			srcInfo, _ := os.Stat(src)
+			destInfo, _ := os.Stat(dest)
+			if os.SameFile(srcInfo, destInfo) != tt.sameFile {
+				t.Errorf("same file = %v, want %v", !tt.sameFile, tt.sameFile)
+			}
+		})
+	}
+}
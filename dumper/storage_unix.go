@@ -0,0 +1,39 @@
+//go:build unix
+
+package dumper
+
+import (
+	"os"
+	"syscall"
+)
+
+func linkCount(info os.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Nlink), true
+}
+
+// fileID returns device and inode of file, hardlinks share the same id
+func fileID(info os.FileInfo) (uint64, uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(stat.Dev), uint64(stat.Ino), true
+}
+
+// freeSpace returns bytes available to unprivileged user on filesystem containing path
+func freeSpace(path string) (uint64, uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	device, _, _ := fileID(info)
+	return uint64(stat.Bavail) * uint64(stat.Bsize), device, nil
+}
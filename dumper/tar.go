@@ -1,6 +1,7 @@
 package dumper
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -28,7 +29,7 @@ func NewTar(global GlobalConfiguration, local Configuration) (*TarDumper, error)
 	return &dumper, nil
 }
 
-func (d *TarDumper) Dump() error {
+func (d *TarDumper) Dump(ctx context.Context) error {
 	sb := strings.Builder{}
 	sb.WriteString(fmt.Sprintf("\"%s\" ", esc(d.globalConfiguration.TarExecutable)))
 	sb.WriteString("--verbose ")
@@ -89,7 +90,7 @@ func (d *TarDumper) Dump() error {
 
 	sb.WriteString(fmt.Sprintf("\"%s\"", esc(targetFile)))
 
-	return d.execute(sb.String())
+	return d.execute(ctx, sb.String())
 }
 
 func splitTargetPath(path string) (string, string) {
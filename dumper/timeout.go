@@ -0,0 +1,30 @@
+package dumper
+
+import (
+	"context"
+	"fmt"
+)
+
+// withTimeout limits ctx with dump timeout, or global timeout when dump timeout is not set
+func (dumper *AbstractDumper) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := dumper.configuration.Timeout
+	if timeout <= 0 {
+		timeout = dumper.globalConfiguration.Timeout
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// interrupted returns error describing why ctx is done, nil when it is not
+func (dumper *AbstractDumper) interrupted(ctx context.Context) error {
+	switch err := ctx.Err(); err {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return fmt.Errorf("dump timed out: %w", err)
+	default:
+		return fmt.Errorf("dump aborted: %w", err)
+	}
+}
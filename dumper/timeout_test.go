@@ -0,0 +1,69 @@
+package dumper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestAbstractDumper_executeInterrupted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("dump command is shell script")
+	}
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		cancel  bool
+		err     string
+		cause   error
+	}{
+		{name: "timed out", timeout: 200 * time.Millisecond, err: "dump timed out: context deadline exceeded", cause: context.DeadlineExceeded},
+		{name: "cancelled", cancel: true, err: "dump aborted: context canceled", cause: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			global := testGlobalConfiguration(t)
+			for _, path := range []string{global.Path, global.TmpPath} {
+				if err := os.MkdirAll(path, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			dumper := &AbstractDumper{
+				globalConfiguration: global,
+				configuration:       Configuration{Name: "db", Latest: true, ForceLatest: true, Timeout: tt.timeout},
+				time:                time.Now(),
+			}
+			t.Setenv("DUMP", dumper.tmpDumpFileName())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(200*time.Millisecond, cancel)
+			}
+
+			//partial dump is written before the command hangs
+			err := dumper.execute(ctx, `echo partial > "$DUMP"; sleep 30`)
+			if err == nil || err.Error() != tt.err || !errors.Is(err, tt.cause) {
+				t.Errorf("execute() error = %v, want %s", err, tt.err)
+			}
+
+			//only the lock file is left in tmp path, nothing is stored
+			entries, err := os.ReadDir(global.TmpPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if entry.Name() != "db.lock" {
+					t.Errorf("tmp file %s left", entry.Name())
+				}
+			}
+			if _, err := os.Stat(dumper.periods()[0].dumpFileName()); err == nil {
+				t.Error("interrupted dump was stored")
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"hash"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -19,6 +20,14 @@ const (
 	HashSha1   = "sha1"
 )
 
+const stagingFileSuffix = ".tmp"
+
+// suffix of replaced files until the new dump, log and checksum are all in place
+const previousFileSuffix = ".previous"
+
+// number of dump log lines in report
+const logTailLines = 50
+
 func makeDirectory(path string) error {
 	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
 		if err := os.MkdirAll(path, 0755); err != nil {
@@ -45,6 +54,44 @@ func copyFile(src, dest string) error {
 		return err
 	}
 
+	return out.Sync()
+}
+
+// stagingFileName returns hidden temporary name in the same directory as path
+func stagingFileName(path string) string {
+	directory, name := filepath.Split(path)
+	return fmt.Sprintf("%s.%s%s", directory, name, stagingFileSuffix)
+}
+
+func isStagingFileName(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, stagingFileSuffix)
+}
+
+// previousFileName returns hidden name of replaced file kept until the new files are committed
+func previousFileName(path string) string {
+	directory, name := filepath.Split(path)
+	return fmt.Sprintf("%s.%s%s", directory, name, previousFileSuffix)
+}
+
+// previousTarget returns name of file replaced by previous file name
+func previousTarget(name string) (string, bool) {
+	if !strings.HasPrefix(name, ".") || !strings.HasSuffix(name, previousFileSuffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(name, "."), previousFileSuffix), true
+}
+
+// syncDirectory flushes directory entries (renames) to disk
+func syncDirectory(path string) error {
+	directory, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer directory.Close()
+
+	if err := directory.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return err
+	}
 	return nil
 }
 
@@ -103,3 +150,37 @@ func formatParam(key, value string) string {
 		return fmt.Sprintf("--%s", key)
 	}
 }
+
+// pipeline returns shell command piping output of command to filter, which fails when any of them fails.
+// sh (dash) has no pipefail, exit status of command is passed through fd 3
+func pipeline(command, filter string) string {
+	return fmt.Sprintf("exec 4>&1; status=$( { { ( %s ); echo $? >&3; } | %s; } 3>&1 >&4 ) || exit $?; exit \"$status\"", command, filter)
+}
+
+// tailFile returns the last lines of text file, empty when file can not be read
+func tailFile(fileName string, lines int) string {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	//long lines of the tail may be cut, only the end of big logs is read
+	const maxTailSize = 64 * 1024
+	if info, err := file.Stat(); err == nil && info.Size() > maxTailSize {
+		if _, err := file.Seek(-maxTailSize, io.SeekEnd); err != nil {
+			return ""
+		}
+	}
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return ""
+	}
+
+	all := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "\n")
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	//finished jobs kept in memory
+	maxJobs = 100
+	//output of job kept in memory
+	maxJobOutput = 256 * 1024
+	//how long stopping server waits for running jobs after they were signalled
+	jobStopWait = 5 * time.Minute
+)
+
+const jobRunning = "running"
+
+// errJobRunning is returned when dump of new job is in a running job already
+var errJobRunning = errors.New("job of dump is running")
+
+// job is box command started by serve command: run, verify or prune
+type job struct {
+	Id       string     `json:"id"`
+	Command  string     `json:"command"`
+	Dumps    []string   `json:"dumps"`
+	Args     []string   `json:"args,omitempty"`
+	Status   string     `json:"status"`
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end,omitempty"`
+	ExitCode int        `json:"exit_code"`
+	Output   string     `json:"-"`
+}
+
+// tailBuffer keeps the last bytes written to it
+type tailBuffer struct {
+	mu   sync.Mutex
+	data []byte
+	size int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if len(b.data) > b.size {
+		b.data = b.data[len(b.data)-b.size:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.data)
+}
+
+type runningJob struct {
+	job
+	output *tailBuffer
+	cmd    *exec.Cmd
+}
+
+// jobRunner runs commands in box processes, so dumps are locked, notified and recorded like in cron runs
+type jobRunner struct {
+	mu         sync.Mutex
+	jobs       []*runningJob
+	executable string
+	args       []string
+	wg         sync.WaitGroup
+}
+
+func newJobRunner(opts *options) (*jobRunner, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	configFileName, err := filepath.Abs(opts.configFileName)
+	if err != nil {
+		return nil, err
+	}
+	return &jobRunner{
+		executable: executable,
+		args:       []string{"--config", configFileName, "--log-level", opts.logLevel, "--log-format", opts.logFormat},
+	}, nil
+}
+
+// start runs command with flags, dumps are selected by exact names,
+// returns running job with errJobRunning when it has any of dumps
+func (runner *jobRunner) start(command string, dumps []string, flags []string) (job, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return job{}, err
+	}
+
+	args := append(append(append([]string{}, runner.args...), command), flags...)
+	for _, name := range dumps {
+		args = append(args, escapePattern(name))
+	}
+
+	j := &runningJob{
+		job: job{
+			Id:      hex.EncodeToString(id),
+			Command: command,
+			Dumps:   dumps,
+			Args:    flags,
+			Status:  jobRunning,
+			Start:   time.Now(),
+		},
+		output: &tailBuffer{size: maxJobOutput},
+	}
+	j.cmd = exec.Command(runner.executable, args...)
+	j.cmd.Stdout = j.output
+	j.cmd.Stderr = j.output
+
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+
+	for _, running := range runner.jobs {
+		if running.End != nil {
+			continue
+		}
+		for _, name := range dumps {
+			if running.hasDump(name) {
+				return running.snapshot(), errJobRunning
+			}
+		}
+	}
+
+	if err := j.cmd.Start(); err != nil {
+		return job{}, err
+	}
+	log.Infof("job %s started: %s %s", j.Id, command, strings.Join(dumps, ", "))
+
+	runner.jobs = append(runner.jobs, j)
+	runner.forget()
+
+	runner.wg.Add(1)
+	go runner.wait(j)
+
+	return j.snapshot(), nil
+}
+
+func (runner *jobRunner) wait(j *runningJob) {
+	defer runner.wg.Done()
+
+	err := j.cmd.Wait()
+
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+
+	end := time.Now()
+	j.End = &end
+	j.Status = statusSuccess
+	if err != nil {
+		j.Status = statusFailed
+		j.ExitCode = 1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			j.ExitCode = exitErr.ExitCode()
+		}
+	}
+
+	log.Infof("job %s %s", j.Id, j.Status)
+}
+
+// forget drops the oldest finished jobs above limit
+func (runner *jobRunner) forget() {
+	for i := 0; len(runner.jobs) > maxJobs && i < len(runner.jobs); {
+		if runner.jobs[i].End != nil {
+			runner.jobs = append(runner.jobs[:i], runner.jobs[i+1:]...)
+			continue
+		}
+		i++
+	}
+}
+
+// get returns job by id
+func (runner *jobRunner) get(id string) (job, bool) {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+
+	for _, j := range runner.jobs {
+		if j.Id == id {
+			return j.snapshot(), true
+		}
+	}
+	return job{}, false
+}
+
+// list returns jobs, newest first
+func (runner *jobRunner) list() []job {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+
+	jobs := make([]job, 0, len(runner.jobs))
+	for i := len(runner.jobs) - 1; i >= 0; i-- {
+		jobs = append(jobs, runner.jobs[i].snapshot())
+	}
+	return jobs
+}
+
+// running returns true when any job of dump is running
+func (runner *jobRunner) running(name string) bool {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+
+	for _, j := range runner.jobs {
+		if j.End == nil && j.hasDump(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// stop signals running jobs to abort and waits for them to clean up
+func (runner *jobRunner) stop() {
+	runner.mu.Lock()
+	for _, j := range runner.jobs {
+		if j.End == nil {
+			if err := j.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+				_ = j.cmd.Process.Kill()
+			}
+		}
+	}
+	runner.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		runner.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(jobStopWait):
+		log.Warnf("running jobs did not stop in %s", jobStopWait)
+	}
+}
+
+func (j *job) hasDump(name string) bool {
+	for _, dump := range j.Dumps {
+		if dump == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *runningJob) snapshot() job {
+	snapshot := j.job
+	snapshot.Output = j.output.String()
+	return snapshot
+}
+
+// escapePattern makes dump name a pattern matching only itself
+func escapePattern(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
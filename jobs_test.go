@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"path"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func waitJob(t *testing.T, runner *jobRunner, id string) job {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if j, ok := runner.get(id); ok && j.End != nil {
+			return j
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return job{}
+}
+
+func TestJobRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("jobs run shell script")
+	}
+
+	//box arguments are $0, command is $1
+	runner := &jobRunner{executable: "/bin/sh", args: []string{"-c", `echo "$@"; exec sleep 5`, "box"}}
+
+	j, err := runner.start("run", []string{"db*"}, []string{"--force"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Status != jobRunning || j.End != nil || !runner.running("db*") {
+		t.Errorf("expected running job, got %+v", j)
+	}
+
+	//dump of running job is refused
+	running, err := runner.start("verify", []string{"other", "db*"}, nil)
+	if !errors.Is(err, errJobRunning) || running.Id != j.Id {
+		t.Errorf("expected running job %s, got %s, %v", j.Id, running.Id, err)
+	}
+	other, err := runner.start("verify", []string{"other"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if jobs := runner.list(); len(jobs) != 2 || jobs[0].Id != other.Id {
+		t.Errorf("expected 2 jobs, newest first, got %+v", jobs)
+	}
+
+	//stop after arguments are written
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if j, _ := runner.get(j.Id); j.Output != "" {
+			break
+		}
+	}
+	runner.stop()
+
+	j = waitJob(t, runner, j.Id)
+	if j.Status != statusFailed || runner.running("db*") {
+		t.Errorf("expected stopped job failed, got %+v", j)
+	}
+	if want := `run --force db\*`; !strings.HasPrefix(j.Output, want) {
+		t.Errorf("expected output %q, got %q", want, j.Output)
+	}
+
+	runner = &jobRunner{executable: "/bin/sh", args: []string{"-c", "exit $0", "3"}}
+	j, err = runner.start("run", []string{"db"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j = waitJob(t, runner, j.Id); j.Status != statusFailed || j.ExitCode != 3 {
+		t.Errorf("expected exit code 3, got %+v", j)
+	}
+}
+
+func TestJobRunner_forget(t *testing.T) {
+	end := time.Now()
+	runner := &jobRunner{}
+	for i := 0; i < maxJobs+5; i++ {
+		j := &runningJob{job: job{Id: string(rune('a' + i%26))}}
+		if i != 0 {
+			j.End = &end
+		}
+		runner.jobs = append(runner.jobs, j)
+	}
+
+	runner.forget()
+
+	if len(runner.jobs) != maxJobs {
+		t.Errorf("expected %d jobs, got %d", maxJobs, len(runner.jobs))
+	}
+	if runner.jobs[0].End != nil {
+		t.Error("expected running job kept")
+	}
+}
+
+func TestEscapePattern(t *testing.T) {
+	for _, name := range []string{"db", "db*", "a?b", "[x]", `back\slash`} {
+		pattern := escapePattern(name)
+		if matched, err := path.Match(pattern, name); err != nil || !matched {
+			t.Errorf("%s: pattern %s does not match name: %v", name, pattern, err)
+		}
+		if name != "db" {
+			if matched, _ := path.Match(pattern, "dbx"); matched {
+				t.Errorf("%s: pattern %s matches other names", name, pattern)
+			}
+		}
+	}
+}
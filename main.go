@@ -3,10 +3,13 @@ package main
 import (
 	"box/configuration"
 	"box/dumper"
-	"box/notifier"
+	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -19,75 +22,142 @@ func init() {
 	log.SetLevel(log.InfoLevel)
 }
 
+type options struct {
+	configFileName string
+	logLevel       string
+	logFormat      string
+	dryRun         bool
+}
+
+type command struct {
+	name        string
+	description string
+	run         func(opts *options, args []string) error
+}
+
+var commands = []command{
+	{"run", "make dumps (default command)", runCommand},
+	{"list", "list configured dumps", listCommand},
+	{"status", "show stored dumps and whether a new dump is due", statusCommand},
+	{"verify", "check stored dumps against their checksums", verifyCommand},
+	{"prune", "apply retention and quotas without making dumps", pruneCommand},
+	{"restore", "verify stored dump and copy it to destination", restoreCommand},
+	{"history", "show results of previous runs from catalog", historyCommand},
+	{"serve", "run web dashboard to browse dumps, run them and download files", serveCommand},
+	{"config", "configuration commands: config check", configCommand},
+}
+
 func main() {
-	config, err := configuration.Read("application.yml")
-	if err != nil {
-		log.Fatalf("unable to read configuration: %s", err)
-		return
-	}
+	opts := options{}
 
-	n := notifier.Notifier{
-		Configuration: config.Notification,
+	flags := flag.NewFlagSet("box", flag.ContinueOnError)
+	flags.StringVar(&opts.configFileName, "config", "application.yml", "configuration file `path`")
+	flags.StringVar(&opts.logLevel, "log-level", "info", "log `level`: trace, debug, info, warning, error")
+	flags.StringVar(&opts.logFormat, "log-format", "text", "log `format`: text or json")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "print what would be done, without executing or changing anything")
+	flags.Usage = func() {
+		out := flags.Output()
+		fmt.Fprintf(out, "Usage: box [global flags] [command] [command flags] [dump name patterns...]\n\nCommands:\n")
+		for _, cmd := range commands {
+			fmt.Fprintf(out, "  %-10s %s\n", cmd.name, cmd.description)
+		}
+		fmt.Fprintf(out, "\nGlobal flags:\n")
+		flags.PrintDefaults()
 	}
-
-	if err := ensureDirectoryExists(config.Global.Path); err != nil {
-		log.Fatalf("unable to create dump path: %s", err)
-		return
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		exitUsage(err)
 	}
-	if err := ensureDirectoryExists(config.Global.TmpPath); err != nil {
-		log.Fatalf("unable to create tmp dump path: %s", err)
-		return
+
+	if err := setupLogging(opts.logLevel, opts.logFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitUsageError)
 	}
 
-	dumpsFilter := make(map[string]bool)
-	for i, arg := range os.Args {
-		if i == 0 {
-			continue
+	cmd, args := findCommand(flags.Args())
+
+	if err := cmd.run(&opts, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		var exit *exitError
+		if errors.As(err, &exit) {
+			log.Errorf("%s: %s", cmd.name, err)
+			os.Exit(exit.code)
 		}
-		dumpsFilter[arg] = true
+		log.Fatalf("%s: %s", cmd.name, err)
 	}
+}
 
-	for _, dump := range config.Dumps {
-		if len(dumpsFilter) > 0 && !dumpsFilter[dump.Name] {
-			continue
+// findCommand returns command named by the first argument and its arguments,
+// without known command all arguments are dump names for run
+func findCommand(args []string) (command, []string) {
+	if len(args) > 0 {
+		for _, cmd := range commands {
+			if cmd.name == args[0] {
+				return cmd, args[1:]
+			}
 		}
+	}
+	return commands[0], args
+}
 
-		var d dumper.Dumper
-		var err error
-
-		log.Infof("%s (%s), latest: %v, daily: %v, weekly: %v, monthly: %v",
-			dump.Name, dump.Type, dump.Latest, dump.Daily, dump.Weekly, dump.Monthly)
-
-		switch dump.Type {
-		case dumper.TypePostgres:
-			d, err = dumper.NewPostgres(config.Global, dump)
-		case dumper.TypeMongo:
-			d, err = dumper.NewMongo5(config.Global, dump)
-		case dumper.TypeMongoLegacy:
-			d, err = dumper.NewMongo4(config.Global, dump)
-		case dumper.TypeFirebirdLegacy:
-			d, err = dumper.NewFirebirdLegacy(config.Global, dump)
-		case dumper.TypeMysql:
-			d, err = dumper.NewMysql(config.Global, dump)
-		case dumper.TypeTar:
-			d, err = dumper.NewTar(config.Global, dump)
-		default:
-			err = errors.New("unknown dumper type")
-		}
-		if err != nil {
-			log.Errorf("%s (%s) unable to create dumper: %s", dump.Name, dump.Type, err)
-			continue
-		}
+// exitUsage exits after flag parse error, flag set already printed error and usage
+func exitUsage(err error) {
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	os.Exit(exitUsageError)
+}
 
-		n.Notify(notifier.StatusInfo, dump.Name, "starting dump")
+func setupLogging(level, format string) error {
+	logLevel, err := log.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("invalid log level: %s", level)
+	}
+	log.SetLevel(logLevel)
 
-		if err := d.Dump(); err != nil {
-			log.Errorf("%s (%s) dump error: %s", dump.Name, dump.Type, err)
-			n.Notify(notifier.StatusError, dump.Name, err.Error())
-		} else {
-			log.Infof("%s (%s) dump done", dump.Name, dump.Type)
-			n.Notify(notifier.StatusSuccess, dump.Name, "dump done")
-		}
+	switch strings.ToLower(format) {
+	case "text":
+		log.SetFormatter(&log.TextFormatter{
+			FullTimestamp: true,
+		})
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("invalid log format: %s", format)
+	}
+
+	return nil
+}
+
+func readConfiguration(opts *options) (*configuration.Configuration, error) {
+	config, err := configuration.Read(opts.configFileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read configuration: %s", err)
+	}
+	config.Global.DryRun = opts.dryRun
+	if secrets := config.Secrets(); len(secrets) > 0 {
+		log.AddHook(newRedactHook(secrets))
+	}
+	return config, nil
+}
+
+// acquireGlobalLock locks tmp path (or dump path) of configuration, runs take shared lock, prune takes exclusive lock
+func acquireGlobalLock(ctx context.Context, config *configuration.Configuration, exclusive bool) (*dumper.Lock, error) {
+	path := config.Global.TmpPath
+	if len(path) == 0 {
+		path = config.Global.Path
+	}
+	lock, err := dumper.AcquireLock(ctx, filepath.Join(path, ".box.lock"), exclusive, config.Global.LockWait)
+	if err != nil {
+		return nil, fmt.Errorf("unable to acquire global lock: %w", err)
+	}
+	return lock, nil
+}
+
+func releaseGlobalLock(lock *dumper.Lock) {
+	if err := lock.Release(); err != nil {
+		log.Errorf("unable to release global lock: %s", err)
 	}
 }
 
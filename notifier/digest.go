@@ -0,0 +1,102 @@
+package notifier
+
+import (
+	"box/dumper"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Digest describes all dumps of a run
+type Digest struct {
+	Host    string
+	Start   time.Time
+	End     time.Time
+	Results []DumpResult
+}
+
+// DumpResult is the outcome of one dump of run
+type DumpResult struct {
+	Name string
+	Type string
+	Tags []string
+	//success, skipped, failed or aborted
+	Result   string
+	Status   Status
+	Duration time.Duration
+	Size     int64
+	Files    []string
+	Error    string
+	LogTail  string
+}
+
+// DigestChannel sends one message per run instead of messages of every dump
+type DigestChannel interface {
+	SendDigest(digest Digest) error
+}
+
+// maxStatus returns the most severe status of results
+func (digest Digest) maxStatus() Status {
+	status := StatusInfo
+	for _, result := range digest.Results {
+		if severity(result.Status) > severity(status) {
+			status = result.Status
+		}
+	}
+	return status
+}
+
+// subject counts dumps by result
+func (digest Digest) subject() string {
+	counts := make(map[string]int)
+	var order []string
+	for _, result := range digest.Results {
+		if counts[result.Result] == 0 {
+			order = append(order, result.Result)
+		}
+		counts[result.Result]++
+	}
+
+	var parts []string
+	for _, result := range order {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[result], result))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "no dumps")
+	}
+
+	return fmt.Sprintf("[box] %s: %s", digest.Host, strings.Join(parts, ", "))
+}
+
+// text is plain text table of results
+func (digest Digest) text() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Run on %s from %s to %s (%s)\n\n", digest.Host,
+		digest.Start.Format(time.RFC3339), digest.End.Format(time.RFC3339), digest.End.Sub(digest.Start).Round(time.Second))
+
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tSTATUS\tDURATION\tSIZE\tERROR")
+	for _, result := range digest.Results {
+		size := "-"
+		if result.Size > 0 {
+			size = dumper.Size(result.Size).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			result.Name, result.Type, result.Result, result.Duration.Round(time.Millisecond), size, result.Error)
+	}
+	_ = w.Flush()
+
+	for _, result := range digest.Results {
+		if len(result.Files) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s files:\n", result.Name)
+		for _, file := range result.Files {
+			fmt.Fprintf(&b, "  %s\n", file)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package notifier
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordEmbed struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Color       int                 `json:"color"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+}
+
+type discordMessage struct {
+	Username string         `json:"username,omitempty"`
+	Content  string         `json:"content"`
+	Embeds   []discordEmbed `json:"embeds"`
+}
+
+// discordChannel sends to discord webhook
+type discordChannel struct {
+	configuration ChannelConfiguration
+}
+
+func (c *discordChannel) Send(message Message) error {
+	embed := discordEmbed{
+		Title:       message.Dump,
+		Description: message.Text,
+		Color:       colorValue(statusColor(message.Status)),
+	}
+	if len(message.Tags) > 0 {
+		embed.Fields = append(embed.Fields, discordEmbedField{Name: "Tags", Value: strings.Join(message.Tags, ", "), Inline: true})
+	}
+
+	return sendJson(http.MethodPost, c.configuration.Url, nil, discordMessage{
+		Username: c.configuration.Username,
+		Embeds:   []discordEmbed{embed},
+	})
+}
+
+// colorValue converts #RRGGBB to number
+func colorValue(color string) int {
+	value, _ := strconv.ParseInt(strings.TrimPrefix(color, "#"), 16, 32)
+	return int(value)
+}
@@ -0,0 +1,177 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SecurityStartTls = "starttls"
+	SecurityTls      = "tls"
+	SecurityNone     = "none"
+)
+
+const emailTimeout = time.Minute
+
+// emailChannel sends run digest with log tails of failed dumps attached
+type emailChannel struct {
+	configuration ChannelConfiguration
+}
+
+// Send does nothing, messages of dumps are sent with run digest
+func (c *emailChannel) Send(message Message) error {
+	return nil
+}
+
+func (c *emailChannel) SendDigest(digest Digest) error {
+	content, err := c.message(digest)
+	if err != nil {
+		return err
+	}
+	return c.send(content)
+}
+
+// message builds multipart message with digest text and log attachments
+func (c *emailChannel) message(digest Digest) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	text, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"8bit"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := text.Write([]byte(digest.text())); err != nil {
+		return nil, err
+	}
+
+	for _, result := range digest.Results {
+		if result.Status != StatusError || len(result.LogTail) == 0 {
+			continue
+		}
+		attachment, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"text/plain; charset=utf-8"},
+			"Content-Transfer-Encoding": {"8bit"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": result.Name + ".log"})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := attachment.Write([]byte(result.LogTail + "\n")); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	headers := []struct {
+		key   string
+		value string
+	}{
+		{"From", c.configuration.From},
+		{"To", strings.Join(c.configuration.To, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", digest.subject())},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": writer.Boundary()})},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&message, "%s: %s\r\n", header.key, header.value)
+	}
+	message.WriteString("\r\n")
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
+
+func (c *emailChannel) send(content []byte) error {
+	port := c.configuration.Port
+	if port == 0 {
+		port = defaultSmtpPort(c.configuration.Security)
+	}
+	address := net.JoinHostPort(c.configuration.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: c.configuration.Host, RootCAs: delivery.rootCAs}
+
+	var conn net.Conn
+	var err error
+	if c.configuration.Security == SecurityTls {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: emailTimeout}, "tcp", address, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", address, emailTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to connect to smtp server: %s", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(emailTimeout))
+
+	client, err := smtp.NewClient(conn, c.configuration.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp: %s", err)
+	}
+	defer client.Close()
+
+	if c.configuration.Security == "" || c.configuration.Security == SecurityStartTls {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not support STARTTLS, set security: none to send unencrypted")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("smtp starttls: %s", err)
+		}
+	}
+
+	if len(c.configuration.Username) != 0 {
+		auth := smtp.PlainAuth("", c.configuration.Username, c.configuration.Password, c.configuration.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("smtp auth: %s", err)
+		}
+	}
+
+	if err := client.Mail(c.configuration.From); err != nil {
+		return fmt.Errorf("smtp from: %s", err)
+	}
+	for _, to := range c.configuration.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("smtp recipient %s: %s", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %s", err)
+	}
+	if _, err := w.Write(content); err != nil {
+		return fmt.Errorf("smtp data: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp data: %s", err)
+	}
+
+	return client.Quit()
+}
+
+func defaultSmtpPort(security string) int {
+	switch security {
+	case SecurityTls:
+		return 465
+	case SecurityNone:
+		return 25
+	default:
+		return 587
+	}
+}
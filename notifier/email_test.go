@@ -0,0 +1,265 @@
+package notifier
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSmtpTls is TLS of fake smtp server
+type fakeSmtpTls struct {
+	//server certificate, STARTTLS is offered when set
+	config *tls.Config
+	//TLS from the first byte instead of STARTTLS
+	implicit bool
+}
+
+// fakeSmtp accepts one session and returns commands and message data, "<tls>" marks start of TLS
+func fakeSmtp(t *testing.T, serverTls fakeSmtpTls) (string, chan []string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := make(chan []string, 1)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { conn.Close() }()
+
+		var lines []string
+		encrypted := false
+		if serverTls.implicit {
+			conn = tls.Server(conn, serverTls.config)
+			encrypted = true
+			lines = append(lines, "<tls>")
+		}
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 localhost ESMTP")
+		data := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				break
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+
+			if data {
+				if line == "." {
+					data = false
+					reply("250 queued")
+				}
+				continue
+			}
+
+			command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch command {
+			case "EHLO":
+				reply("250-localhost")
+				if serverTls.config != nil && !encrypted {
+					reply("250-STARTTLS")
+				}
+				reply("250 AUTH PLAIN")
+			case "STARTTLS":
+				reply("220 ready to start TLS")
+				conn = tls.Server(conn, serverTls.config)
+				reader = bufio.NewReader(conn)
+				encrypted = true
+				lines = append(lines, "<tls>")
+			case "AUTH":
+				reply("235 authenticated")
+			case "DATA":
+				data = true
+				reply("354 go ahead")
+			case "QUIT":
+				reply("221 bye")
+				session <- lines
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+		session <- lines
+	}()
+
+	return listener.Addr().String(), session
+}
+
+// selfSignedTls returns server TLS configuration for 127.0.0.1 and pool trusting its certificate
+func selfSignedTls(t *testing.T) (*tls.Config, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "box test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, pool
+}
+
+func waitSession(t *testing.T, session chan []string) []string {
+	select {
+	case lines := <-session:
+		return lines
+	case <-time.After(5 * time.Second):
+		t.Fatal("smtp session timed out")
+		return nil
+	}
+}
+
+func TestEmailChannel_SendDigest(t *testing.T) {
+	address, session := fakeSmtp(t, fakeSmtpTls{})
+	host, portText, _ := net.SplitHostPort(address)
+	port, _ := strconv.Atoi(portText)
+
+	configuration := ChannelConfiguration{
+		Type:     TypeEmail,
+		Enabled:  true,
+		Host:     host,
+		Port:     port,
+		Security: SecurityNone,
+		From:     "box@example.com",
+		To:       []string{"a@example.com", "b@example.com"},
+	}
+
+	notifier, err := New(Configuration{}, []ChannelConfiguration{configuration})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	notifier.SendDigest(Digest{
+		Host:  "backup-host",
+		Start: start,
+		End:   start.Add(time.Minute),
+		Results: []DumpResult{
+			{Name: "pg", Type: "postgres", Result: "success", Status: StatusSuccess, Duration: time.Second, Size: 2048, Files: []string{"/backup/pg/daily/pg.sql.gz"}},
+			{Name: "db", Type: "mysql", Result: "failed", Status: StatusError, Error: "exit status 2", LogTail: "mysqldump: access denied"},
+		},
+	})
+
+	transcript := strings.Join(waitSession(t, session), "\n")
+
+	for _, expected := range []string{
+		"MAIL FROM:<box@example.com>",
+		"RCPT TO:<a@example.com>",
+		"RCPT TO:<b@example.com>",
+		"Subject: [box] backup-host: 1 success, 1 failed",
+		"/backup/pg/daily/pg.sql.gz",
+		`filename=db.log`,
+		"mysqldump: access denied",
+	} {
+		if !strings.Contains(transcript, expected) {
+			t.Errorf("expected %q in smtp session:\n%s", expected, transcript)
+		}
+	}
+	if strings.Contains(transcript, "filename=pg.log") {
+		t.Errorf("log of successful dump attached")
+	}
+}
+
+func TestEmailChannel_send(t *testing.T) {
+	serverConfig, pool := selfSignedTls(t)
+
+	rootCAs := delivery.rootCAs
+	delivery.rootCAs = pool
+	defer func() {
+		delivery.rootCAs = rootCAs
+	}()
+
+	tests := []struct {
+		name      string
+		security  string
+		serverTls fakeSmtpTls
+		untrusted bool
+		err       string
+	}{
+		{name: "starttls", security: SecurityStartTls, serverTls: fakeSmtpTls{config: serverConfig}},
+		{name: "starttls by default", serverTls: fakeSmtpTls{config: serverConfig}},
+		{name: "implicit tls", security: SecurityTls, serverTls: fakeSmtpTls{config: serverConfig, implicit: true}},
+		{name: "starttls not supported", security: SecurityStartTls, err: "smtp server does not support STARTTLS"},
+		{name: "untrusted certificate", security: SecurityStartTls, serverTls: fakeSmtpTls{config: serverConfig}, untrusted: true, err: "smtp starttls:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, session := fakeSmtp(t, tt.serverTls)
+			host, portText, _ := net.SplitHostPort(address)
+			port, _ := strconv.Atoi(portText)
+
+			delivery.rootCAs = pool
+			if tt.untrusted {
+				delivery.rootCAs = x509.NewCertPool()
+			}
+
+			channel := &emailChannel{ChannelConfiguration{
+				Type:     TypeEmail,
+				Host:     host,
+				Port:     port,
+				Security: tt.security,
+				Username: "box",
+				Password: "secret",
+				From:     "box@example.com",
+				To:       []string{"a@example.com"},
+			}}
+			err := channel.send([]byte("Subject: test\r\n\r\nbody\r\n"))
+			lines := waitSession(t, session)
+
+			if len(tt.err) != 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("send() error = %v, want %s", err, tt.err)
+				}
+				for _, line := range lines {
+					if strings.HasPrefix(line, "AUTH") || strings.HasPrefix(line, "MAIL") {
+						t.Errorf("unexpected %q without TLS", line)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			//credentials and message are sent only after TLS started
+			transcript := strings.Join(lines, "\n")
+			tlsStart := strings.Index(transcript, "<tls>")
+			plain := "AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00box\x00secret"))
+			if tlsStart < 0 || strings.Index(transcript, plain) < tlsStart || strings.Index(transcript, "MAIL FROM:<box@example.com>") < tlsStart {
+				t.Errorf("expected %q after TLS in smtp session:\n%s", plain, transcript)
+			}
+		})
+	}
+}
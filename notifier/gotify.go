@@ -0,0 +1,40 @@
+package notifier
+
+import (
+	"net/http"
+	"strings"
+)
+
+type gotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+// gotifyChannel sends message with application token
+type gotifyChannel struct {
+	configuration ChannelConfiguration
+}
+
+func (c *gotifyChannel) Send(message Message) error {
+	headers := map[string]string{"X-Gotify-Key": c.configuration.Token}
+
+	return sendJson(http.MethodPost, strings.TrimRight(c.configuration.Url, "/")+"/message", headers, gotifyMessage{
+		Title:    message.Dump,
+		Message:  plainText(message),
+		Priority: gotifyPriority(message.Status),
+	})
+}
+
+func gotifyPriority(status Status) int {
+	switch status {
+	case StatusError:
+		return 8
+	case StatusWarning:
+		return 5
+	case StatusSuccess:
+		return 2
+	default:
+		return 1
+	}
+}
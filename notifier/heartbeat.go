@@ -0,0 +1,43 @@
+package notifier
+
+import (
+	"box/dumper"
+	"fmt"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PingStart sends start ping of heartbeat, name is dump name or run
+func PingStart(heartbeat dumper.Heartbeat, name string) {
+	start, _, _ := heartbeat.PingUrls()
+	ping(name, "start", start, "")
+}
+
+// PingFinish sends success ping when exit status is 0 and failure ping otherwise, body is shown by monitor
+func PingFinish(heartbeat dumper.Heartbeat, name string, exitStatus int, body string) {
+	_, success, failure := heartbeat.PingUrls()
+
+	body = fmt.Sprintf("exit status: %d\n%s", exitStatus, body)
+	if exitStatus == 0 {
+		ping(name, "success", success, body)
+	} else {
+		ping(name, "failure", failure, body)
+	}
+}
+
+// ping posts body as text, failed ping is logged only and not kept in outbox, monitor alerts on missing pings anyway
+func ping(name, kind, url, body string) {
+	if len(url) == 0 {
+		return
+	}
+	err := delivery.do(delivery.context(), envelope{
+		Method:  http.MethodPost,
+		Url:     url,
+		Headers: map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+		Body:    []byte(body),
+	})
+	if err != nil {
+		log.Errorf("%s: unable to send heartbeat %s ping: %s", name, kind, err)
+	}
+}
@@ -0,0 +1,50 @@
+package notifier
+
+import (
+	"box/dumper"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPing(t *testing.T) {
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, request{r.Method, r.URL.RequestURI(), string(body), r.Header})
+	}))
+	defer server.Close()
+
+	heartbeat := dumper.Heartbeat{Url: server.URL + "/uuid"}
+	PingStart(heartbeat, "db")
+	PingFinish(heartbeat, "db", 0, "done")
+	PingFinish(heartbeat, "db", 2, "log")
+
+	kuma := dumper.Heartbeat{FailureUrl: server.URL + "/push?status=down"}
+	PingStart(kuma, "db")
+	PingFinish(kuma, "db", 0, "done")
+	PingFinish(kuma, "db", 1, "log")
+
+	expected := []struct {
+		path string
+		body string
+	}{
+		{"/uuid/start", ""},
+		{"/uuid", "exit status: 0\ndone"},
+		{"/uuid/fail", "exit status: 2\nlog"},
+		{"/push?status=down", "exit status: 1\nlog"},
+	}
+	if len(requests) != len(expected) {
+		t.Fatalf("expected %d pings, got %d", len(expected), len(requests))
+	}
+	for i, e := range expected {
+		if requests[i].path != e.path || requests[i].body != e.body {
+			t.Errorf("ping %d = %s %q, want %s %q", i, requests[i].path, requests[i].body, e.path, e.body)
+		}
+		if !strings.HasPrefix(requests[i].header.Get("Content-Type"), "text/plain") {
+			t.Errorf("ping %d is not text", i)
+		}
+	}
+}
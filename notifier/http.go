@@ -0,0 +1,407 @@
+package notifier
+
+import (
+	"box/dumper"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultDeliveryTimeout = 10 * time.Second
+	defaultRetryDelay      = time.Second
+	maxRetryDelay          = time.Minute
+	defaultOutboxMaxAge    = 24 * time.Hour
+)
+
+// DeliveryConfiguration describes how notifications and heartbeat pings are sent over HTTP
+type DeliveryConfiguration struct {
+	//time limit of one request, 10s by default
+	Timeout time.Duration `yaml:"timeout"`
+
+	//number of times failed request is retried, requests rejected with 4xx status are not retried
+	Retries int `yaml:"retries"`
+
+	//delay before the first retry, doubled for every next retry, 1s by default
+	RetryDelay time.Duration `yaml:"retry-delay"`
+
+	//proxy url, HTTPS_PROXY and HTTP_PROXY environment variables are used by default
+	Proxy string `yaml:"proxy"`
+
+	//PEM file of CA certificates trusted besides system ones
+	CaFile string `yaml:"ca-file"`
+
+	//directory of undelivered notifications resent on the next run, .box-outbox in global path by default
+	Outbox string `yaml:"outbox"`
+
+	//undelivered notifications older than duration are dropped, 24h by default
+	OutboxMaxAge time.Duration `yaml:"outbox-max-age"`
+}
+
+// Delivery sends HTTP requests of notifications with retries, undelivered requests are kept in outbox
+type Delivery struct {
+	client       *http.Client
+	rootCAs      *x509.CertPool
+	retries      int
+	retryDelay   time.Duration
+	outbox       string
+	outboxMaxAge time.Duration
+
+	//cancels requests and retry delays, run context
+	ctx context.Context
+	//hosts failed in this run, their requests go to outbox without retries
+	mu   sync.Mutex
+	down map[string]bool
+}
+
+// envelope is request of notification kept in outbox
+type envelope struct {
+	Method  string            `json:"method"`
+	Url     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    []byte            `json:"body,omitempty"`
+	Created time.Time         `json:"created"`
+}
+
+// statusError is response status other than 2xx
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("notification status: %d %s", e.code, e.body)
+}
+
+// errHostDown is returned for requests to host failed earlier in the run
+var errHostDown = errors.New("host failed earlier in this run")
+
+// delivery is used by all channels and heartbeat pings, without outbox until UseDelivery is called
+var delivery = &Delivery{
+	client:     &http.Client{Timeout: defaultDeliveryTimeout},
+	retryDelay: defaultRetryDelay,
+}
+
+// NewDelivery creates delivery, outbox is the default outbox directory
+func NewDelivery(configuration DeliveryConfiguration, outbox string) (*Delivery, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if len(configuration.Proxy) != 0 {
+		proxy, err := url.Parse(configuration.Proxy)
+		if err != nil || len(proxy.Host) == 0 {
+			return nil, fmt.Errorf("invalid proxy url %q", configuration.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	var rootCAs *x509.CertPool
+	if len(configuration.CaFile) != 0 {
+		pem, err := os.ReadFile(configuration.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read ca-file: %s", err)
+		}
+		rootCAs, err = x509.SystemCertPool()
+		if err != nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in ca-file %s", configuration.CaFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+	}
+
+	d := &Delivery{
+		client:       &http.Client{Transport: transport, Timeout: configuration.Timeout},
+		rootCAs:      rootCAs,
+		retries:      configuration.Retries,
+		retryDelay:   configuration.RetryDelay,
+		outbox:       configuration.Outbox,
+		outboxMaxAge: configuration.OutboxMaxAge,
+	}
+	if d.client.Timeout <= 0 {
+		d.client.Timeout = defaultDeliveryTimeout
+	}
+	if d.retryDelay <= 0 {
+		d.retryDelay = defaultRetryDelay
+	}
+	if len(d.outbox) == 0 {
+		d.outbox = outbox
+	}
+	if d.outboxMaxAge <= 0 {
+		d.outboxMaxAge = defaultOutboxMaxAge
+	}
+
+	return d, nil
+}
+
+// UseDelivery makes all channels and heartbeat pings use delivery, requests and retry delays are cancelled with ctx
+func UseDelivery(ctx context.Context, d *Delivery) {
+	d.ctx = ctx
+	delivery = d
+}
+
+// ResendOutbox resends undelivered notifications of delivery in use
+func ResendOutbox() {
+	delivery.resendOutbox()
+}
+
+// sendJson sends payload marshalled to JSON
+func sendJson(method, url string, headers map[string]string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("unable to marshal notification: %s", err)
+	}
+
+	jsonHeaders := map[string]string{"Content-Type": "application/json"}
+	for key, value := range headers {
+		jsonHeaders[key] = value
+	}
+
+	return send(method, url, jsonHeaders, body)
+}
+
+// send delivers notification, request failed after retries is kept in outbox
+func send(method, url string, headers map[string]string, body []byte) error {
+	request := envelope{
+		Method:  method,
+		Url:     url,
+		Headers: headers,
+		Body:    body,
+		Created: time.Now(),
+	}
+
+	err := delivery.do(delivery.context(), request)
+	if err == nil || !retryable(err) || len(delivery.outbox) == 0 {
+		return err
+	}
+
+	if storeErr := delivery.store(request); storeErr != nil {
+		return fmt.Errorf("%w, unable to keep in outbox: %s", err, storeErr)
+	}
+	return fmt.Errorf("%w, kept in outbox", err)
+}
+
+func (d *Delivery) context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
+}
+
+// do makes request, retries with exponentially growing delay,
+// host failed after retries is marked down, so the next requests to it fail at once and a run is not slowed by every message
+func (d *Delivery) do(ctx context.Context, request envelope) error {
+	host := requestHost(request.Url)
+	if d.isDown(host) {
+		return fmt.Errorf("%s: %w", host, errHostDown)
+	}
+
+	delay := d.retryDelay
+
+	for attempt := 0; ; attempt++ {
+		err := d.request(ctx, request)
+		if err == nil || !retryable(err) {
+			return err
+		}
+		if attempt >= d.retries || ctx.Err() != nil {
+			d.markDown(host)
+			return err
+		}
+
+		log.Warnf("notification request failed: %s, retrying in %s", err, delay)
+		select {
+		case <-ctx.Done():
+			d.markDown(host)
+			return err
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+func (d *Delivery) isDown(host string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.down[host]
+}
+
+func (d *Delivery) markDown(host string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.down == nil {
+		d.down = make(map[string]bool)
+	}
+	d.down[host] = true
+}
+
+func requestHost(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// request makes one request, response status other than 2xx is an error
+func (d *Delivery) request(ctx context.Context, request envelope) error {
+	r, err := http.NewRequestWithContext(ctx, request.Method, request.Url, bytes.NewReader(request.Body))
+	if err != nil {
+		return redactUrlError(err)
+	}
+	for key, value := range request.Headers {
+		r.Header.Set(key, value)
+	}
+
+	response, err := d.client.Do(r)
+	if err != nil {
+		return redactUrlError(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return &statusError{response.StatusCode, string(bytes.TrimSpace(responseBody))}
+	}
+
+	return nil
+}
+
+// redactUrlError removes path and query from url of error, they contain tokens of telegram and webhook urls
+func redactUrlError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = redactUrl(urlErr.URL)
+	}
+	return err
+}
+
+func redactUrl(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil || len(u.Host) == 0 {
+		return "***"
+	}
+	return fmt.Sprintf("%s://%s/***", u.Scheme, u.Host)
+}
+
+// retryable is true for network errors, server errors and rate limiting
+func retryable(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.code >= 500 || status.code == http.StatusTooManyRequests
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) || errors.Is(err, errHostDown)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// store writes request to outbox, readable by owner only as it contains tokens
+func (d *Delivery) store(request envelope) error {
+	if err := os.MkdirAll(d.outbox, 0700); err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	name := fmt.Sprintf("%d-%s.json", request.Created.UnixNano(), hex.EncodeToString(suffix))
+
+	tmpFileName := filepath.Join(d.outbox, "."+name+".tmp")
+	if err := os.WriteFile(tmpFileName, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpFileName, filepath.Join(d.outbox, name))
+}
+
+// resendOutbox sends undelivered notifications in order they were made, once without retries,
+// outbox of another running box is skipped
+func (d *Delivery) resendOutbox() {
+	if len(d.outbox) == 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(d.outbox)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Errorf("unable to read notification outbox: %s", err)
+		}
+		return
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") && strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	sort.Strings(names)
+
+	lock, err := dumper.AcquireLock(context.Background(), filepath.Join(d.outbox, ".lock"), true, 0)
+	if err != nil {
+		log.Warnf("notification outbox is not resent: %s", err)
+		return
+	}
+	defer lock.Release()
+
+	log.Infof("resending %d undelivered notifications", len(names))
+
+	for _, name := range names {
+		fileName := filepath.Join(d.outbox, name)
+
+		var request envelope
+		content, err := os.ReadFile(fileName)
+		if err == nil {
+			err = json.Unmarshal(content, &request)
+		}
+		if err != nil {
+			log.Errorf("unable to read undelivered notification %s: %s", name, err)
+			continue
+		}
+
+		host := requestHost(request.Url)
+
+		if time.Since(request.Created) > d.outboxMaxAge {
+			log.Warnf("undelivered notification of %s dropped: older than %s", request.Created.Format(time.RFC3339), d.outboxMaxAge)
+		} else if d.isDown(host) {
+			continue
+		} else if err := d.request(d.context(), request); err != nil && retryable(err) {
+			log.Errorf("unable to resend notification of %s: %s", request.Created.Format(time.RFC3339), err)
+			d.markDown(host)
+			continue
+		} else if err != nil {
+			log.Errorf("undelivered notification of %s dropped: %s", request.Created.Format(time.RFC3339), err)
+		}
+
+		if err := os.Remove(fileName); err != nil {
+			log.Errorf("unable to remove notification from outbox: %s", err)
+		}
+	}
+}
@@ -1,11 +1,12 @@
 package notifier
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
 	log "github.com/sirupsen/logrus"
-	"net/http"
 )
 
 type Status int
@@ -14,8 +15,10 @@ const (
 	StatusSuccess Status = 1
 	StatusInfo    Status = 2
 	StatusError   Status = 3
+	StatusWarning Status = 4
 )
 
+// Configuration is the legacy notification block, it is sent to mattermost
 type Configuration struct {
 	Enabled   bool   `yaml:"enabled"`
 	Url       string `yaml:"url"`
@@ -23,80 +26,332 @@ type Configuration struct {
 	Username  string `yaml:"username"`
 	IconUrl   string `yaml:"icon-url"`
 	IconEmoji string `yaml:"icon-emoji"`
+
+	//message templates by status: info, success, warning or error
+	Templates map[string]string `yaml:"templates"`
 }
 
-type mattermostAttachmentField struct {
-	ShortField bool   `json:"short"`
-	Title      string `json:"title"`
-	Value      string `json:"value"`
+// ChannelConfiguration describes one notification channel, fields used depend on type
+type ChannelConfiguration struct {
+	//mattermost, slack, telegram, discord, teams, matrix, ntfy, gotify, webhook or email
+	Type string `yaml:"type"`
+
+	//name of channel in logs, type by default
+	Name string `yaml:"name"`
+
+	Enabled bool `yaml:"enabled"`
+
+	//the lowest status sent: info, success, warning or error
+	MinSeverity string `yaml:"min-severity"`
+
+	//webhook url; telegram api, matrix homeserver or gotify server url; ntfy topic url
+	Url string `yaml:"url"`
+
+	//telegram bot token, matrix access token, gotify application token or ntfy access token
+	Token string `yaml:"token"`
+
+	//mattermost or slack channel
+	Channel string `yaml:"channel"`
+
+	//mattermost, slack or discord user name, smtp user name
+	Username string `yaml:"username"`
+
+	//mattermost or slack icon
+	IconUrl   string `yaml:"icon-url"`
+	IconEmoji string `yaml:"icon-emoji"`
+
+	//telegram chat id
+	ChatId string `yaml:"chat-id"`
+
+	//matrix room id
+	RoomId string `yaml:"room-id"`
+
+	//extra webhook request headers
+	Headers map[string]string `yaml:"headers"`
+
+	//smtp server, port is 587 for starttls, 465 for tls and 25 without encryption by default
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+
+	//smtp password
+	Password string `yaml:"password"`
+
+	//smtp connection security: starttls (default), tls or none
+	Security string `yaml:"security"`
+
+	//email sender and recipients
+	From string   `yaml:"from"`
+	To   []string `yaml:"to"`
+
+	//message templates by status: info, success, warning or error
+	Templates map[string]string `yaml:"templates"`
 }
 
-type mattermostMessageAttachment struct {
-	Fallback string                      `json:"fallback"`
-	Color    string                      `json:"color"`
-	Text     string                      `json:"text"`
-	Fields   []mattermostAttachmentField `json:"fields"`
+// Message is a notification about dump
+type Message struct {
+	Status Status
+	Dump   string
+	Type   string
+	Tags   []string
+	Text   string
+
+	//dump details used in templates, empty before dump
+	Size     int64
+	Duration time.Duration
+	Checksum string
+	Files    []string
+	Rotated  []string
+	Log      string
 }
 
-type mattermostNotification struct {
-	Text        string                        `json:"text"`
-	Channel     string                        `json:"channel"`
-	Username    string                        `json:"username"`
-	IconUrl     string                        `json:"icon_url"`
-	IconEmoji   string                        `json:"icon_emoji"`
-	Attachments []mattermostMessageAttachment `json:"attachments"`
+// Channel delivers messages to a chat or service
+type Channel interface {
+	Send(message Message) error
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
+type channel struct {
+	name        string
+	minSeverity int
+	templates   map[Status]*template.Template
+	channel     Channel
+}
+
+type digestChannel struct {
+	name        string
+	minSeverity int
+	channel     DigestChannel
+}
+
 type Notifier struct {
-	Configuration Configuration
+	channels []channel
+	digests  []digestChannel
 }
 
-func (notifier *Notifier) Notify(status Status, dumperName string, message string) {
-	if !notifier.Configuration.Enabled {
-		return
+// New creates notifier sending to enabled channels, legacy configuration is a mattermost channel
+func New(legacy Configuration, configurations []ChannelConfiguration) (*Notifier, error) {
+	notifier := &Notifier{}
+
+	if legacy.Enabled {
+		templates, err := ParseTemplates(legacy.Templates)
+		if err != nil {
+			return nil, err
+		}
+
+		notifier.channels = append(notifier.channels, channel{
+			name:        TypeMattermost,
+			minSeverity: severity(StatusInfo),
+			templates:   templates,
+			channel: &mattermostChannel{ChannelConfiguration{
+				Url:       legacy.Url,
+				Channel:   legacy.Channel,
+				Username:  legacy.Username,
+				IconUrl:   legacy.IconUrl,
+				IconEmoji: legacy.IconEmoji,
+			}},
+		})
 	}
 
-	attachment := mattermostMessageAttachment{
-		Fallback: fmt.Sprintf("%s: %s", dumperName, message),
-		Color:    statusColor(status),
-		Text:     message,
-		Fields: []mattermostAttachmentField{
-			{
-				Title: "Backup",
-				Value: dumperName,
-			},
-		},
+	for _, configuration := range configurations {
+		if !configuration.Enabled {
+			continue
+		}
+
+		c, err := NewChannel(configuration)
+		if err != nil {
+			return nil, err
+		}
+
+		minSeverity, err := ParseSeverity(configuration.MinSeverity)
+		if err != nil {
+			return nil, err
+		}
+
+		templates, err := ParseTemplates(configuration.Templates)
+		if err != nil {
+			return nil, err
+		}
+
+		name := configuration.Name
+		if len(name) == 0 {
+			name = configuration.Type
+		}
+
+		if digest, ok := c.(DigestChannel); ok {
+			notifier.digests = append(notifier.digests, digestChannel{name, minSeverity, digest})
+			continue
+		}
+
+		notifier.channels = append(notifier.channels, channel{name, minSeverity, templates, c})
+	}
+
+	return notifier, nil
+}
+
+// Notify sends message to channels of message status min severity, text is made with channel template when it is set
+func (notifier *Notifier) Notify(message Message) {
+	if notifier == nil {
+		return
 	}
 
-	notification := mattermostNotification{
-		Channel:     notifier.Configuration.Channel,
-		Username:    notifier.Configuration.Username,
-		IconUrl:     notifier.Configuration.IconUrl,
-		IconEmoji:   notifier.Configuration.IconEmoji,
-		Attachments: []mattermostMessageAttachment{attachment},
+	for _, c := range notifier.channels {
+		if severity(message.Status) < c.minSeverity {
+			continue
+		}
+		notifier.send(c, message)
 	}
+}
+
+// send renders and sends message to channel, failure of channel never stops the run
+func (notifier *Notifier) send(c channel, message Message) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("unable to send notification to %s: %v", c.name, r)
+		}
+	}()
 
-	notificationJson, err := json.Marshal(notification)
+	text, err := render(c.templates, message)
 	if err != nil {
-		log.Errorf("unable to marshal notification: %s", err)
+		log.Errorf("unable to render notification to %s: %s", c.name, err)
+	}
+	message.Text = text
+	if err := c.channel.Send(message); err != nil {
+		log.Errorf("unable to send notification to %s: %s", c.name, err)
+	}
+}
+
+// SendDigest sends run digest to digest channels, when any dump has status of channel min severity
+func (notifier *Notifier) SendDigest(digest Digest) {
+	if notifier == nil {
 		return
 	}
 
-	response, err := http.Post(notifier.Configuration.Url, "application/json", bytes.NewBuffer(notificationJson))
-	if err != nil {
-		log.Errorf("unable to send notification: %s", err)
+	for _, c := range notifier.digests {
+		if severity(digest.maxStatus()) < c.minSeverity {
+			continue
+		}
+		if err := c.channel.SendDigest(digest); err != nil {
+			log.Errorf("unable to send digest to %s: %s", c.name, err)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+const (
+	TypeMattermost = "mattermost"
+	TypeSlack      = "slack"
+	TypeTelegram   = "telegram"
+	TypeDiscord    = "discord"
+	TypeTeams      = "teams"
+	TypeMatrix     = "matrix"
+	TypeNtfy       = "ntfy"
+	TypeGotify     = "gotify"
+	TypeWebhook    = "webhook"
+	TypeEmail      = "email"
+)
+
+// Types lists supported channel types
+var Types = []string{TypeMattermost, TypeSlack, TypeTelegram, TypeDiscord, TypeTeams, TypeMatrix, TypeNtfy, TypeGotify, TypeWebhook, TypeEmail}
+
+// RequiredFields returns configuration keys a channel of type can not work without
+func RequiredFields(channelType string) []string {
+	switch channelType {
+	case TypeTelegram:
+		return []string{"token", "chat-id"}
+	case TypeMatrix:
+		return []string{"url", "token", "room-id"}
+	case TypeGotify:
+		return []string{"url", "token"}
+	case TypeEmail:
+		return []string{"host", "from", "to"}
+	default:
+		return []string{"url"}
 	}
-	defer response.Body.Close()
+}
 
-	if response.StatusCode != http.StatusOK {
-		log.Errorf("notification status: %d", response.StatusCode)
+// NewChannel creates channel of configured type
+func NewChannel(configuration ChannelConfiguration) (Channel, error) {
+	switch configuration.Type {
+	case TypeMattermost:
+		return &mattermostChannel{configuration}, nil
+	case TypeSlack:
+		return &slackChannel{configuration}, nil
+	case TypeTelegram:
+		return &telegramChannel{configuration}, nil
+	case TypeDiscord:
+		return &discordChannel{configuration}, nil
+	case TypeTeams:
+		return &teamsChannel{configuration}, nil
+	case TypeMatrix:
+		return &matrixChannel{configuration}, nil
+	case TypeNtfy:
+		return &ntfyChannel{configuration}, nil
+	case TypeGotify:
+		return &gotifyChannel{configuration}, nil
+	case TypeWebhook:
+		return &webhookChannel{configuration}, nil
+	case TypeEmail:
+		switch configuration.Security {
+		case "", SecurityStartTls, SecurityTls, SecurityNone:
+		default:
+			return nil, fmt.Errorf("unknown email security %q, expected one of: starttls, tls, none", configuration.Security)
+		}
+		return &emailChannel{configuration}, nil
+	default:
+		return nil, fmt.Errorf("unknown notification channel type %q", configuration.Type)
 	}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
+var severityNames = []string{"info", "success", "warning", "error"}
+
+// ParseSeverity returns severity level of status name, empty name is the lowest level
+func ParseSeverity(name string) (int, error) {
+	if len(name) == 0 {
+		return 0, nil
+	}
+	for level, severityName := range severityNames {
+		if strings.EqualFold(name, severityName) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q, expected one of: %s", name, strings.Join(severityNames, ", "))
+}
+
+func severity(status Status) int {
+	switch status {
+	case StatusInfo:
+		return 0
+	case StatusSuccess:
+		return 1
+	case StatusWarning:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// statusOfSeverity is the reverse of severity
+func statusOfSeverity(level int) Status {
+	switch level {
+	case 0:
+		return StatusInfo
+	case 1:
+		return StatusSuccess
+	case 2:
+		return StatusWarning
+	default:
+		return StatusError
+	}
+}
+
+func statusName(status Status) string {
+	return severityNames[severity(status)]
+}
+
 func statusColor(status Status) string {
 	switch status {
 	case StatusSuccess:
@@ -105,7 +360,14 @@ func statusColor(status Status) string {
 		return "#0000AA"
 	case StatusError:
 		return "#AA0000"
+	case StatusWarning:
+		return "#DD8800"
 	default:
 		return "#888888"
 	}
 }
+
+// title is a short one line description of message
+func (message Message) title() string {
+	return fmt.Sprintf("%s: %s", message.Dump, message.Text)
+}
@@ -0,0 +1,138 @@
+package notifier
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type request struct {
+	method string
+	path   string
+	body   string
+	header http.Header
+}
+
+func TestNotifier_channels(t *testing.T) {
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, request{r.Method, r.URL.Path, string(body), r.Header})
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		configuration ChannelConfiguration
+		method        string
+		path          string
+		body          string
+	}{
+		{ChannelConfiguration{Type: TypeMattermost, Url: server.URL + "/hooks/1"}, "POST", "/hooks/1", `"title":"Backup","value":"db"`},
+		{ChannelConfiguration{Type: TypeSlack, Url: server.URL + "/slack"}, "POST", "/slack", `"text":"db: dump failed"`},
+		{ChannelConfiguration{Type: TypeTelegram, Url: server.URL, Token: "T", ChatId: "42"}, "POST", "/botT/sendMessage", `"chat_id":"42","text":"[ERROR] db: dump failed`},
+		{ChannelConfiguration{Type: TypeDiscord, Url: server.URL + "/discord"}, "POST", "/discord", `"color":11141120`},
+		{ChannelConfiguration{Type: TypeTeams, Url: server.URL + "/teams"}, "POST", "/teams", `"themeColor":"AA0000"`},
+		{ChannelConfiguration{Type: TypeMatrix, Url: server.URL, Token: "T", RoomId: "!room:example.com"}, "PUT", "/_matrix/client/v3/rooms/!room:example.com/send/m.room.message/", `"msgtype":"m.text"`},
+		{ChannelConfiguration{Type: TypeNtfy, Url: server.URL + "/backups"}, "POST", "/backups", `dump failed`},
+		{ChannelConfiguration{Type: TypeGotify, Url: server.URL, Token: "T"}, "POST", "/message", `"priority":8`},
+		{ChannelConfiguration{Type: TypeWebhook, Url: server.URL + "/hook", Headers: map[string]string{"X-Key": "K"}}, "POST", "/hook", `"status":"error","dump":"db","tags":["critical"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.configuration.Type, func(t *testing.T) {
+			requests = nil
+			tt.configuration.Enabled = true
+			tt.configuration.MinSeverity = "warning"
+
+			notifier, err := New(Configuration{}, []ChannelConfiguration{tt.configuration})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			notifier.Notify(Message{Status: StatusInfo, Dump: "db", Tags: []string{"critical"}, Text: "starting dump"})
+			notifier.Notify(Message{Status: StatusError, Dump: "db", Tags: []string{"critical"}, Text: "dump failed"})
+
+			if len(requests) != 1 {
+				t.Fatalf("expected 1 request above min severity, got %d", len(requests))
+			}
+			r := requests[0]
+			if r.method != tt.method || !strings.HasPrefix(r.path, tt.path) {
+				t.Errorf("request = %s %s, want %s %s", r.method, r.path, tt.method, tt.path)
+			}
+			if !strings.Contains(r.body, tt.body) {
+				t.Errorf("body %s does not contain %s", r.body, tt.body)
+			}
+			if tt.configuration.Type == TypeWebhook && r.header.Get("X-Key") != "K" {
+				t.Errorf("webhook header not sent")
+			}
+		})
+	}
+}
+
+func TestNotifier_legacy(t *testing.T) {
+	received := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	notifier, err := New(Configuration{Enabled: true, Url: server.URL}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//failed delivery is logged only
+	notifier.Notify(Message{Status: StatusInfo, Dump: "db", Text: "starting dump"})
+	if received != 1 {
+		t.Errorf("expected legacy mattermost channel to receive info message, got %d requests", received)
+	}
+}
+
+func TestNotifier_templates(t *testing.T) {
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, request{r.Method, r.URL.Path, string(body), r.Header})
+	}))
+	defer server.Close()
+
+	notifier, err := New(Configuration{}, []ChannelConfiguration{{
+		Type:    TypeNtfy,
+		Enabled: true,
+		Url:     server.URL,
+		Templates: map[string]string{
+			"success": `{{ .Name }} ({{ .Type }}) {{ size .Size }} in {{ .Duration }}, files: {{ join .Files ", " }}, rotated: {{ len .Rotated }}`,
+			"error":   `{{ upper .Status }} {{ .Name }}: {{ .Text }}{{ "\n" }}{{ tail 2 .Log }}`,
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifier.Notify(Message{Status: StatusInfo, Dump: "db", Text: "starting dump"})
+	notifier.Notify(Message{Status: StatusSuccess, Dump: "db", Type: "postgres", Size: 2048, Duration: 3 * time.Second,
+		Files: []string{"daily/db.sql.gz", "latest.sql.gz"}, Rotated: []string{"daily/old.sql.gz"}})
+	notifier.Notify(Message{Status: StatusError, Dump: "db", Text: "exit status 1", Log: "one\ntwo\nthree\n"})
+
+	expected := []string{
+		"starting dump",
+		"db (postgres) 2.00 kB in 3s, files: daily/db.sql.gz, latest.sql.gz, rotated: 1",
+		"ERROR db: exit status 1\ntwo\nthree",
+	}
+	if len(requests) != len(expected) {
+		t.Fatalf("expected %d requests, got %d", len(expected), len(requests))
+	}
+	for i, body := range expected {
+		if requests[i].body != body {
+			t.Errorf("message %d = %q, want %q", i, requests[i].body, body)
+		}
+	}
+
+	if _, err := ParseTemplates(map[string]string{"failure": "x"}); err == nil {
+		t.Errorf("expected unknown status error")
+	}
+}
@@ -0,0 +1,33 @@
+package notifier
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type matrixMessage struct {
+	MsgType string `json:"msgtype"`
+	Body    string `json:"body"`
+}
+
+// matrixChannel sends text message to room with client-server api
+type matrixChannel struct {
+	configuration ChannelConfiguration
+}
+
+func (c *matrixChannel) Send(message Message) error {
+	//transaction id makes retried requests idempotent
+	transactionId := fmt.Sprintf("box-%d", time.Now().UnixNano())
+	requestUrl := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		strings.TrimRight(c.configuration.Url, "/"), url.PathEscape(c.configuration.RoomId), transactionId)
+
+	headers := map[string]string{"Authorization": "Bearer " + c.configuration.Token}
+
+	return sendJson(http.MethodPut, requestUrl, headers, matrixMessage{
+		MsgType: "m.text",
+		Body:    plainText(message),
+	})
+}
@@ -0,0 +1,67 @@
+package notifier
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type mattermostAttachmentField struct {
+	ShortField bool   `json:"short"`
+	Title      string `json:"title"`
+	Value      string `json:"value"`
+}
+
+type mattermostMessageAttachment struct {
+	Fallback string                      `json:"fallback"`
+	Color    string                      `json:"color"`
+	Text     string                      `json:"text"`
+	Fields   []mattermostAttachmentField `json:"fields"`
+}
+
+type mattermostNotification struct {
+	Text        string                        `json:"text"`
+	Channel     string                        `json:"channel"`
+	Username    string                        `json:"username"`
+	IconUrl     string                        `json:"icon_url"`
+	IconEmoji   string                        `json:"icon_emoji"`
+	Attachments []mattermostMessageAttachment `json:"attachments"`
+}
+
+// mattermostChannel sends to mattermost incoming webhook
+type mattermostChannel struct {
+	configuration ChannelConfiguration
+}
+
+func (c *mattermostChannel) Send(message Message) error {
+	return sendJson(http.MethodPost, c.configuration.Url, nil, mattermostNotification{
+		Channel:     c.configuration.Channel,
+		Username:    c.configuration.Username,
+		IconUrl:     c.configuration.IconUrl,
+		IconEmoji:   c.configuration.IconEmoji,
+		Attachments: []mattermostMessageAttachment{mattermostAttachment(message)},
+	})
+}
+
+// mattermostAttachment is also understood by slack
+func mattermostAttachment(message Message) mattermostMessageAttachment {
+	attachment := mattermostMessageAttachment{
+		Fallback: fmt.Sprintf("%s: %s", message.Dump, message.Text),
+		Color:    statusColor(message.Status),
+		Text:     message.Text,
+		Fields: []mattermostAttachmentField{
+			{
+				Title: "Backup",
+				Value: message.Dump,
+			},
+		},
+	}
+	if len(message.Tags) > 0 {
+		attachment.Fields = append(attachment.Fields, mattermostAttachmentField{
+			ShortField: true,
+			Title:      "Tags",
+			Value:      strings.Join(message.Tags, ", "),
+		})
+	}
+	return attachment
+}
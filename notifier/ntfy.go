@@ -0,0 +1,35 @@
+package notifier
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ntfyChannel publishes message to ntfy topic url
+type ntfyChannel struct {
+	configuration ChannelConfiguration
+}
+
+func (c *ntfyChannel) Send(message Message) error {
+	headers := map[string]string{
+		"Title":    message.Dump,
+		"Priority": ntfyPriority(message.Status),
+		"Tags":     strings.Join(append([]string{statusName(message.Status)}, message.Tags...), ","),
+	}
+	if len(c.configuration.Token) != 0 {
+		headers["Authorization"] = "Bearer " + c.configuration.Token
+	}
+
+	return send(http.MethodPost, c.configuration.Url, headers, []byte(message.Text))
+}
+
+func ntfyPriority(status Status) string {
+	switch status {
+	case StatusError:
+		return "high"
+	case StatusInfo:
+		return "low"
+	default:
+		return "default"
+	}
+}
@@ -0,0 +1,228 @@
+package notifier
+
+import (
+	"box/dumper"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stateLockWait is how long Save waits for another box process saving state
+const stateLockWait = 10 * time.Second
+
+// DumpState is the last known status of dump
+type DumpState struct {
+	//info, success, warning or error
+	Status string `json:"status"`
+	Text   string `json:"text"`
+	//when dump got status
+	Since time.Time `json:"since"`
+	//when status was sent last time
+	Notified time.Time `json:"notified,omitempty"`
+}
+
+// State is persisted between runs for notification policies
+type State struct {
+	Dumps map[string]*DumpState `json:"dumps"`
+	//when the last daily summary was sent
+	Summary time.Time `json:"summary,omitempty"`
+
+	fileName string
+	//dumps changed by this run, the others are kept as saved by concurrent runs
+	updated map[string]bool
+	//dumps of configuration, nil to keep all
+	known map[string]bool
+}
+
+// LoadState reads state file, missing file is an empty state
+func LoadState(fileName string) (*State, error) {
+	state := &State{
+		Dumps:    make(map[string]*DumpState),
+		fileName: fileName,
+		updated:  make(map[string]bool),
+	}
+	if err := state.read(); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (state *State) read() error {
+	content, err := os.ReadFile(state.fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if err := json.Unmarshal(content, state); err != nil {
+		return fmt.Errorf("%s: %s", state.fileName, err)
+	}
+	if state.Dumps == nil {
+		state.Dumps = make(map[string]*DumpState)
+	}
+	return nil
+}
+
+// Retain forgets dumps removed from configuration when state is saved
+func (state *State) Retain(names []string) {
+	state.known = make(map[string]bool)
+	for _, name := range names {
+		state.known[name] = true
+	}
+}
+
+// Save writes dumps updated by this run over the current state file
+func (state *State) Save() error {
+	lock, err := dumper.AcquireLock(context.Background(), state.fileName+".lock", true, stateLockWait)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	saved, err := LoadState(state.fileName)
+	if err != nil {
+		return err
+	}
+	for name := range state.updated {
+		saved.Dumps[name] = state.Dumps[name]
+	}
+	if state.Summary.After(saved.Summary) {
+		saved.Summary = state.Summary
+	}
+	if state.known != nil {
+		for name := range saved.Dumps {
+			if !state.known[name] {
+				delete(saved.Dumps, name)
+			}
+		}
+	}
+
+	content, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpFileName := filepath.Join(filepath.Dir(state.fileName), "."+filepath.Base(state.fileName)+".tmp")
+	if err := os.WriteFile(tmpFileName, append(content, '\n'), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFileName, state.fileName)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// Allowed returns true when policy sends message sent during dump, like start of dump
+func Allowed(policy dumper.NotificationPolicy, message Message) bool {
+	switch policy.Send {
+	case dumper.NotifyFailures, dumper.NotifyChanges:
+		return isFailure(message.Status)
+	default:
+		return true
+	}
+}
+
+// Filter records message as the result of dump and returns message to send, false when policy suppresses it
+func (state *State) Filter(policy dumper.NotificationPolicy, message Message, now time.Time) (Message, bool) {
+	previous, known := state.Dumps[message.Dump]
+	current := &DumpState{
+		Status: statusName(message.Status),
+		Text:   message.Text,
+		Since:  now,
+	}
+	repeated := known && previous.Status == current.Status
+	if repeated {
+		current.Since = previous.Since
+		current.Notified = previous.Notified
+	}
+	state.Dumps[message.Dump] = current
+	state.updated[message.Dump] = true
+
+	failure := isFailure(message.Status)
+
+	send := true
+	switch policy.Send {
+	case dumper.NotifyFailures:
+		send = failure
+	case dumper.NotifyChanges:
+		send = !repeated && (known || failure)
+	}
+
+	if send && repeated && failure && policy.SuppressRepeats && previous.Text == current.Text {
+		send = false
+	}
+
+	if !send && repeated && failure && policy.RemindAfter > 0 && now.Sub(current.Notified) >= policy.RemindAfter {
+		send = true
+		message.Text = fmt.Sprintf("still %s since %s: %s", current.Status, current.Since.Format("2006-01-02 15:04"), message.Text)
+	} else if known && !failure && isFailure(statusOf(previous.Status)) {
+		message.Text = fmt.Sprintf("recovered: %s", message.Text)
+	}
+
+	if send {
+		current.Notified = now
+	}
+
+	return message, send
+}
+
+// DailySummary returns message with status of every dump, once a day after time of day
+func (state *State) DailySummary(timeOfDay string, now time.Time) (Message, bool) {
+	if len(timeOfDay) == 0 || len(state.Dumps) == 0 {
+		return Message{}, false
+	}
+
+	clock, err := time.Parse("15:04", timeOfDay)
+	if err != nil {
+		return Message{}, false
+	}
+	scheduled := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if now.Before(scheduled) || !state.Summary.Before(scheduled) {
+		return Message{}, false
+	}
+	state.Summary = now
+
+	names := make([]string, 0, len(state.Dumps))
+	for name := range state.Dumps {
+		if state.known == nil || state.known[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	status := StatusInfo
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		dump := state.Dumps[name]
+		if dumpStatus := statusOf(dump.Status); severity(dumpStatus) > severity(status) {
+			status = dumpStatus
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s since %s, %s", name, dump.Status, dump.Since.Format("2006-01-02 15:04"), dump.Text))
+	}
+
+	return Message{
+		Status: status,
+		Dump:   "daily summary",
+		Text:   strings.Join(lines, "\n"),
+	}, true
+}
+
+func isFailure(status Status) bool {
+	return severity(status) >= severity(StatusWarning)
+}
+
+// statusOf returns status of name, unknown name is info
+func statusOf(name string) Status {
+	level, err := ParseSeverity(name)
+	if err != nil {
+		return StatusInfo
+	}
+	return statusOfSeverity(level)
+}
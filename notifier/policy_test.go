@@ -0,0 +1,111 @@
+package notifier
+
+import (
+	"box/dumper"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestState_Filter(t *testing.T) {
+	type step struct {
+		status Status
+		text   string
+		hours  int
+		sent   string
+	}
+
+	tests := []struct {
+		name   string
+		policy dumper.NotificationPolicy
+		steps  []step
+	}{
+		{"all", dumper.NotificationPolicy{}, []step{
+			{StatusSuccess, "dump done", 0, "dump done"},
+			{StatusError, "exit status 1", 1, "exit status 1"},
+			{StatusError, "exit status 1", 2, "exit status 1"},
+			{StatusSuccess, "dump done", 3, "recovered: dump done"},
+		}},
+		{"failures", dumper.NotificationPolicy{Send: dumper.NotifyFailures}, []step{
+			{StatusSuccess, "dump done", 0, ""},
+			{StatusWarning, "aborted", 1, "aborted"},
+			{StatusSuccess, "dump done", 2, ""},
+		}},
+		{"changes", dumper.NotificationPolicy{Send: dumper.NotifyChanges}, []step{
+			{StatusSuccess, "dump done", 0, ""},
+			{StatusSuccess, "dump done", 1, ""},
+			{StatusError, "exit status 1", 2, "exit status 1"},
+			{StatusError, "exit status 2", 3, ""},
+			{StatusSuccess, "dump done", 4, "recovered: dump done"},
+		}},
+		{"suppress repeats with reminder", dumper.NotificationPolicy{SuppressRepeats: true, RemindAfter: 12 * time.Hour}, []step{
+			{StatusError, "exit status 1", 0, "exit status 1"},
+			{StatusError, "exit status 1", 6, ""},
+			{StatusError, "exit status 2", 7, "exit status 2"},
+			{StatusError, "exit status 2", 18, ""},
+			{StatusError, "exit status 2", 19, "still error since 2024-01-01 00:00: exit status 2"},
+			{StatusError, "exit status 2", 20, ""},
+		}},
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := LoadState(filepath.Join(t.TempDir(), "state.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, s := range tt.steps {
+				message, ok := state.Filter(tt.policy, Message{Status: s.status, Dump: "db", Text: s.text}, start.Add(time.Duration(s.hours)*time.Hour))
+				sent := ""
+				if ok {
+					sent = message.Text
+				}
+				if sent != s.sent {
+					t.Errorf("step %d: sent %q, want %q", i, sent, s.sent)
+				}
+			}
+		})
+	}
+}
+
+func TestState_Save(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "state.json")
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	policy := dumper.NotificationPolicy{Send: dumper.NotifyChanges}
+
+	//runs of different dumps keep statuses of each other
+	first, _ := LoadState(fileName)
+	second, _ := LoadState(fileName)
+	first.Filter(policy, Message{Status: StatusError, Dump: "a", Text: "failed"}, now)
+	second.Filter(policy, Message{Status: StatusSuccess, Dump: "b", Text: "done"}, now)
+	if err := first.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := LoadState(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Dumps) != 2 {
+		t.Fatalf("expected 2 dumps in state, got %d", len(state.Dumps))
+	}
+
+	if _, ok := state.DailySummary("10:00", now); ok {
+		t.Errorf("summary sent before time of day")
+	}
+	message, ok := state.DailySummary("08:00", now)
+	if !ok || message.Status != StatusError {
+		t.Errorf("expected error summary, got %v %+v", ok, message)
+	}
+	if _, ok := state.DailySummary("08:00", now.Add(time.Hour)); ok {
+		t.Errorf("summary sent twice a day")
+	}
+	if _, ok := state.DailySummary("08:00", now.Add(24*time.Hour)); !ok {
+		t.Errorf("summary not sent next day")
+	}
+}
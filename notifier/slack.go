@@ -0,0 +1,19 @@
+package notifier
+
+import "net/http"
+
+// slackChannel sends to slack incoming webhook, attachments are the same as in mattermost
+type slackChannel struct {
+	configuration ChannelConfiguration
+}
+
+func (c *slackChannel) Send(message Message) error {
+	return sendJson(http.MethodPost, c.configuration.Url, nil, mattermostNotification{
+		Text:        message.title(),
+		Channel:     c.configuration.Channel,
+		Username:    c.configuration.Username,
+		IconUrl:     c.configuration.IconUrl,
+		IconEmoji:   c.configuration.IconEmoji,
+		Attachments: []mattermostMessageAttachment{mattermostAttachment(message)},
+	})
+}
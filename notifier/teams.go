@@ -0,0 +1,36 @@
+package notifier
+
+import (
+	"net/http"
+	"strings"
+)
+
+type teamsMessageCard struct {
+	Type       string `json:"@type"`
+	Context    string `json:"@context"`
+	ThemeColor string `json:"themeColor"`
+	Summary    string `json:"summary"`
+	Title      string `json:"title"`
+	Text       string `json:"text"`
+}
+
+// teamsChannel sends message card to microsoft teams incoming webhook
+type teamsChannel struct {
+	configuration ChannelConfiguration
+}
+
+func (c *teamsChannel) Send(message Message) error {
+	text := message.Text
+	if len(message.Tags) > 0 {
+		text += "\n\nTags: " + strings.Join(message.Tags, ", ")
+	}
+
+	return sendJson(http.MethodPost, c.configuration.Url, nil, teamsMessageCard{
+		Type:       "MessageCard",
+		Context:    "http://schema.org/extensions",
+		ThemeColor: strings.TrimPrefix(statusColor(message.Status), "#"),
+		Summary:    message.title(),
+		Title:      message.Dump,
+		Text:       text,
+	})
+}
@@ -0,0 +1,41 @@
+package notifier
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const telegramApiUrl = "https://api.telegram.org"
+
+type telegramMessage struct {
+	ChatId string `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+// telegramChannel sends with bot api sendMessage
+type telegramChannel struct {
+	configuration ChannelConfiguration
+}
+
+func (c *telegramChannel) Send(message Message) error {
+	apiUrl := c.configuration.Url
+	if len(apiUrl) == 0 {
+		apiUrl = telegramApiUrl
+	}
+	url := fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimRight(apiUrl, "/"), c.configuration.Token)
+
+	return sendJson(http.MethodPost, url, nil, telegramMessage{
+		ChatId: c.configuration.ChatId,
+		Text:   plainText(message),
+	})
+}
+
+// plainText is message for channels without formatting
+func plainText(message Message) string {
+	text := fmt.Sprintf("[%s] %s: %s", strings.ToUpper(statusName(message.Status)), message.Dump, message.Text)
+	if len(message.Tags) > 0 {
+		text += fmt.Sprintf("\ntags: %s", strings.Join(message.Tags, ", "))
+	}
+	return text
+}
@@ -0,0 +1,92 @@
+package notifier
+
+import (
+	"box/dumper"
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateData is available in message templates
+type templateData struct {
+	//info, success, warning or error
+	Status string
+	Name   string
+	Type   string
+	Tags   []string
+	//default message text
+	Text     string
+	Size     int64
+	Duration time.Duration
+	Checksum string
+	Files    []string
+	Rotated  []string
+	Log      string
+}
+
+var templateFunctions = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"size": func(size int64) string {
+		return dumper.Size(size).String()
+	},
+	//tail returns the last lines of text
+	"tail": func(lines int, text string) string {
+		all := strings.Split(strings.TrimRight(text, "\n"), "\n")
+		if lines >= 0 && len(all) > lines {
+			all = all[len(all)-lines:]
+		}
+		return strings.Join(all, "\n")
+	},
+}
+
+// ParseTemplates parses message templates by status name
+func ParseTemplates(templates map[string]string) (map[Status]*template.Template, error) {
+	parsed := make(map[Status]*template.Template)
+
+	for name, text := range templates {
+		level, err := ParseSeverity(name)
+		if err != nil || len(name) == 0 {
+			return nil, fmt.Errorf("unknown template status %q, expected one of: %s", name, strings.Join(severityNames, ", "))
+		}
+
+		t, err := template.New(name).Funcs(templateFunctions).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %s", name, err)
+		}
+
+		parsed[statusOfSeverity(level)] = t
+	}
+
+	return parsed, nil
+}
+
+// render returns message text made with template of message status, or the message text when there is no template
+func render(templates map[Status]*template.Template, message Message) (string, error) {
+	t, ok := templates[message.Status]
+	if !ok {
+		return message.Text, nil
+	}
+
+	var text bytes.Buffer
+	err := t.Execute(&text, templateData{
+		Status:   statusName(message.Status),
+		Name:     message.Dump,
+		Type:     message.Type,
+		Tags:     message.Tags,
+		Text:     message.Text,
+		Size:     message.Size,
+		Duration: message.Duration,
+		Checksum: message.Checksum,
+		Files:    message.Files,
+		Rotated:  message.Rotated,
+		Log:      message.Log,
+	})
+	if err != nil {
+		return message.Text, fmt.Errorf("template %s: %s", statusName(message.Status), err)
+	}
+
+	return strings.TrimSpace(text.String()), nil
+}
@@ -0,0 +1,29 @@
+package notifier
+
+import (
+	"net/http"
+	"time"
+)
+
+type webhookMessage struct {
+	Status string    `json:"status"`
+	Dump   string    `json:"dump"`
+	Tags   []string  `json:"tags"`
+	Text   string    `json:"text"`
+	Time   time.Time `json:"time"`
+}
+
+// webhookChannel posts message as JSON to any url
+type webhookChannel struct {
+	configuration ChannelConfiguration
+}
+
+func (c *webhookChannel) Send(message Message) error {
+	return sendJson(http.MethodPost, c.configuration.Url, c.configuration.Headers, webhookMessage{
+		Status: statusName(message.Status),
+		Dump:   message.Dump,
+		Tags:   message.Tags,
+		Text:   message.Text,
+		Time:   time.Now(),
+	})
+}
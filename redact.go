@@ -0,0 +1,31 @@
+package main
+
+import (
+	"box/dumper"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// redactHook replaces secrets in log messages and fields
+type redactHook struct {
+	replacer *strings.Replacer
+}
+
+func newRedactHook(secrets []string) *redactHook {
+	return &redactHook{dumper.NewSecretReplacer(secrets)}
+}
+
+func (hook *redactHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (hook *redactHook) Fire(entry *log.Entry) error {
+	entry.Message = hook.replacer.Replace(entry.Message)
+	for key, value := range entry.Data {
+		if s, ok := value.(string); ok {
+			entry.Data[key] = hook.replacer.Replace(s)
+		}
+	}
+	return nil
+}
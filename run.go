@@ -0,0 +1,507 @@
+package main
+
+import (
+	"box/configuration"
+	"box/dumper"
+	"box/notifier"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	//invalid flags or configuration
+	exitUsageError = 1
+	//some of selected dumps failed
+	exitPartialFailure = 2
+	//all selected dumps failed, or run could not start them
+	exitTotalFailure = 3
+)
+
+const (
+	statusSuccess = "success"
+	statusSkipped = "skipped"
+	statusFailed  = "failed"
+	statusAborted = "aborted"
+	//dump would be made, dry run only
+	statusPlanned = "planned"
+)
+
+// exitError makes the process exit with code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// dumpResult is the outcome of one dump in run
+type dumpResult struct {
+	Name     string        `json:"name"`
+	Type     dumper.Type   `json:"type"`
+	Tags     []string      `json:"tags,omitempty"`
+	Status   string        `json:"status"`
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"-"`
+	Seconds  float64       `json:"duration_seconds"`
+	Size     int64         `json:"size"`
+	Attempts int           `json:"attempts,omitempty"`
+	//exit status of dump command, 1 for other errors
+	ExitStatus int `json:"exit_status"`
+	//sha256 of dump, kept for scripts reading summary before checksums were added
+	Checksum  string            `json:"checksum,omitempty"`
+	Checksums map[string]string `json:"checksums,omitempty"`
+	Files     []string          `json:"files,omitempty"`
+	Rotated   []string          `json:"rotated,omitempty"`
+	//files of this and other dumps deleted to fit into max-total-size
+	Pruned []string `json:"pruned,omitempty"`
+	Error  string   `json:"error,omitempty"`
+	//errors of hooks which ran after the dump was stored
+	HookErrors []string `json:"hook_errors,omitempty"`
+	LogTail    string   `json:"-"`
+}
+
+type runSummary struct {
+	Start   time.Time    `json:"start"`
+	End     time.Time    `json:"end"`
+	Results []dumpResult `json:"results"`
+}
+
+func runCommand(opts *options, args []string) error {
+	sel := selection{}
+	flags := newCommandFlags("run", "[dump name patterns...]")
+	sel.register(flags)
+	summaryJson := flags.String("summary-json", "", "write run summary as JSON to `path` (- for stdout)")
+	force := flags.Bool("force", false, "make dumps even when they are not needed, files of current periods are overwritten")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := readConfiguration(opts)
+	if err != nil {
+		return err
+	}
+	config.Global.Force = *force
+
+	dumps, err := sel.dumps(config, flags.Args())
+	if err != nil {
+		return err
+	}
+
+	//the first SIGINT or SIGTERM cancels running dump, the second one kills box
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	//ctx is replaced by run timeout below, timeout must not restore default signal handling or cancel delivery
+	go func(ctx context.Context) {
+		<-ctx.Done()
+		stop()
+	}(ctx)
+
+	var n *notifier.Notifier
+	var state *notifier.State
+	if !opts.dryRun {
+		delivery, err := notifier.NewDelivery(config.NotificationDelivery, filepath.Join(config.Global.Path, ".box-outbox"))
+		if err != nil {
+			return err
+		}
+		//signal stops waiting for notification retries, run timeout does not, results of aborted dumps are still sent
+		notifier.UseDelivery(ctx, delivery)
+
+		n, err = notifier.New(config.Notification, config.Notifications)
+		if err != nil {
+			return err
+		}
+		state, err = notifier.LoadState(notificationStateFileName(config))
+		if err != nil {
+			return &exitError{exitTotalFailure, fmt.Errorf("unable to read notification state: %s", err)}
+		}
+	}
+
+	if !opts.dryRun {
+		if err := ensureDirectoryExists(config.Global.Path); err != nil {
+			return &exitError{exitTotalFailure, fmt.Errorf("unable to create dump path: %s", err)}
+		}
+		if err := ensureDirectoryExists(config.Global.TmpPath); err != nil {
+			return &exitError{exitTotalFailure, fmt.Errorf("unable to create tmp dump path: %s", err)}
+		}
+	}
+
+	if config.Global.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Global.RunTimeout)
+		defer cancel()
+	}
+
+	if !opts.dryRun {
+		//runs of different dumps may overlap, prune waits for them
+		lock, err := acquireGlobalLock(ctx, config, false)
+		if err != nil {
+			return &exitError{exitTotalFailure, err}
+		}
+		defer releaseGlobalLock(lock)
+
+		notifier.ResendOutbox()
+	}
+
+	summary := runSummary{
+		Start: time.Now(),
+	}
+
+	//results are added to catalog as soon as dump finishes, run may be killed
+	record := func(result dumpResult) {
+		summary.Results = append(summary.Results, result)
+		if opts.dryRun {
+			return
+		}
+		if err := appendCatalog(catalogFileName(config), result); err != nil {
+			log.Errorf("%s (%s) unable to write catalog: %s", result.Name, result.Type, err)
+		}
+	}
+
+	if !opts.dryRun {
+		notifier.PingStart(config.Heartbeat, "run")
+	}
+
+	//results are notified only when status of dump changed, depending on notification policy
+	notifyResult := func(dump dumper.Configuration, result dumpResult, status notifier.Status, text string) {
+		if state == nil {
+			return
+		}
+		if message, ok := state.Filter(notificationPolicy(config, dump), result.message(status, text), time.Now()); ok {
+			n.Notify(message)
+		}
+	}
+
+	for _, dump := range dumps {
+		if err := ctx.Err(); err != nil {
+			log.Warnf("%s (%s) not started: run %s", dump.Name, dump.Type, runInterruption(err))
+			result := dumpResult{Name: dump.Name, Type: dump.Type, Tags: dump.Tags, Start: time.Now()}
+			result = result.finish(dumper.Report{}, fmt.Errorf("run %s: %w", runInterruption(err), err))
+			record(result)
+			status := notifier.StatusError
+			if result.Status == statusAborted {
+				status = notifier.StatusWarning
+			}
+			notifyResult(dump, result, status, fmt.Sprintf("not started: %s", result.Error))
+			continue
+		}
+
+		log.Infof("%s (%s), latest: %v, hourly: %v, daily: %v, weekly: %v, monthly: %v, yearly: %v",
+			dump.Name, dump.Type, dump.Latest, dump.Hourly, dump.Daily, dump.Weekly, dump.Monthly, dump.Yearly)
+
+		result := dumpResult{
+			Name:  dump.Name,
+			Type:  dump.Type,
+			Tags:  dump.Tags,
+			Start: time.Now(),
+		}
+
+		d, err := dumper.New(config.Global, dump)
+		if err != nil {
+			log.Errorf("%s (%s) unable to create dumper: %s", dump.Name, dump.Type, err)
+			result = result.finish(dumper.Report{}, err)
+			record(result)
+			notifyResult(dump, result, notifier.StatusError, err.Error())
+			continue
+		}
+
+		policy := notificationPolicy(config, dump)
+		notify := func(message notifier.Message) {
+			if notifier.Allowed(policy, message) {
+				n.Notify(message)
+			}
+		}
+
+		notify(result.message(notifier.StatusInfo, "starting dump"))
+		if !opts.dryRun {
+			notifier.PingStart(dump.Heartbeat, dump.Name)
+		}
+
+		err = d.Dump(ctx)
+		result = result.finish(d.Report(), err)
+		record(result)
+
+		if opts.dryRun {
+			if err != nil {
+				log.Errorf("%s (%s) dry run error: %s", dump.Name, dump.Type, err)
+			}
+			continue
+		}
+
+		exitStatus, body := result.heartbeat()
+		notifier.PingFinish(dump.Heartbeat, dump.Name, exitStatus, body)
+
+		if pruned := d.Report().Pruned; len(pruned) > 0 {
+			notify(result.message(notifier.StatusWarning, fmt.Sprintf("max-total-size exceeded, pruned: %s", strings.Join(pruned, ", "))))
+		}
+		if hookErrors := d.Report().HookErrors; len(hookErrors) > 0 {
+			notify(result.message(notifier.StatusWarning, fmt.Sprintf("dump stored, but %s", strings.Join(hookErrors, ", "))))
+		}
+
+		if errors.Is(err, dumper.ErrLocked) {
+			log.Warnf("%s (%s) skipped: %s", dump.Name, dump.Type, err)
+			notifyResult(dump, result, notifier.StatusWarning, fmt.Sprintf("skipped: %s", err))
+		} else if result.Status == statusAborted {
+			log.Warnf("%s (%s) %s", dump.Name, dump.Type, err)
+			notifyResult(dump, result, notifier.StatusWarning, err.Error())
+		} else if err != nil {
+			log.Errorf("%s (%s) dump error: %s", dump.Name, dump.Type, err)
+			notifyResult(dump, result, notifier.StatusError, err.Error())
+		} else {
+			message := "dump done"
+			if retries := result.Attempts - 1; retries > 0 {
+				message = fmt.Sprintf("dump succeeded after %d retries", retries)
+			}
+			log.Infof("%s (%s) %s", dump.Name, dump.Type, message)
+			notifyResult(dump, result, notifier.StatusSuccess, message)
+		}
+	}
+
+	summary.End = time.Now()
+
+	n.SendDigest(summary.digest())
+
+	if state != nil {
+		names := make([]string, 0, len(config.Dumps))
+		for _, dump := range config.Dumps {
+			names = append(names, dump.Name)
+		}
+		state.Retain(names)
+
+		if message, ok := state.DailySummary(config.NotificationPolicy.DailySummary, time.Now()); ok {
+			n.Notify(message)
+		}
+		if err := state.Save(); err != nil {
+			log.Errorf("unable to save notification state: %s", err)
+		}
+	}
+
+	exitErr := summary.exitError()
+
+	if !opts.dryRun {
+		var table strings.Builder
+		_ = summary.print(&table)
+		exitStatus := 0
+		var e *exitError
+		if errors.As(exitErr, &e) {
+			exitStatus = e.code
+		}
+		notifier.PingFinish(config.Heartbeat, "run", exitStatus, table.String())
+	}
+
+	if err := summary.print(os.Stdout); err != nil {
+		return err
+	}
+	if len(*summaryJson) != 0 {
+		if err := summary.writeJson(*summaryJson); err != nil {
+			return fmt.Errorf("unable to write summary: %s", err)
+		}
+	}
+
+	return exitErr
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (result dumpResult) finish(report dumper.Report, err error) dumpResult {
+	result.End = time.Now()
+	result.Duration = result.End.Sub(result.Start)
+	result.Seconds = result.Duration.Seconds()
+	result.Size = report.Size
+	result.Attempts = report.Attempts
+	result.Checksum = report.Checksum
+	result.Checksums = report.Checksums
+	result.Files = report.Files
+	result.Rotated = report.Rotated
+	result.Pruned = report.Pruned
+	result.LogTail = report.LogTail
+	result.HookErrors = report.HookErrors
+
+	switch {
+	case errors.Is(err, dumper.ErrLocked):
+		//another run makes this dump
+		result.Status = statusSkipped
+		result.Error = err.Error()
+	case errors.Is(err, context.Canceled):
+		result.Status = statusAborted
+		result.Error = err.Error()
+	case err != nil:
+		result.Status = statusFailed
+		result.Error = err.Error()
+	case report.Dumped:
+		result.Status = statusSuccess
+	case report.Planned:
+		result.Status = statusPlanned
+	default:
+		result.Status = statusSkipped
+	}
+
+	if result.Status == statusFailed || result.Status == statusAborted {
+		result.ExitStatus = 1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			result.ExitStatus = exitErr.ExitCode()
+		}
+	}
+
+	return result
+}
+
+// heartbeat returns exit status and ping body with log excerpt
+func (result dumpResult) heartbeat() (int, string) {
+	if result.ExitStatus == 0 {
+		body := fmt.Sprintf("%s: %s in %s", result.Status, formatSize(result.Size), result.Duration.Round(time.Millisecond))
+		if len(result.Files) > 0 {
+			body += "\n" + strings.Join(result.Files, "\n")
+		}
+		return 0, body
+	}
+
+	body := result.Error
+	if len(result.LogTail) > 0 {
+		body += "\n\n" + result.LogTail
+	}
+	return result.ExitStatus, body
+}
+
+// notificationPolicy returns policy of dump, global policy when dump has none
+func notificationPolicy(config *configuration.Configuration, dump dumper.Configuration) dumper.NotificationPolicy {
+	if dump.NotificationPolicy != nil {
+		return *dump.NotificationPolicy
+	}
+	return config.NotificationPolicy
+}
+
+// notificationStateFileName is where the last known dump statuses are kept between runs
+func notificationStateFileName(config *configuration.Configuration) string {
+	return filepath.Join(config.Global.Path, ".box-notifications.json")
+}
+
+// message is notification about result with dump details for templates
+func (result dumpResult) message(status notifier.Status, text string) notifier.Message {
+	return notifier.Message{
+		Status:   status,
+		Dump:     result.Name,
+		Type:     string(result.Type),
+		Tags:     result.Tags,
+		Text:     text,
+		Size:     result.Size,
+		Duration: result.Duration,
+		Checksum: result.Checksum,
+		Files:    result.Files,
+		Rotated:  result.Rotated,
+		Log:      result.LogTail,
+	}
+}
+
+// digest converts summary for digest notifications
+func (summary *runSummary) digest() notifier.Digest {
+	host, _ := os.Hostname()
+
+	digest := notifier.Digest{
+		Host:  host,
+		Start: summary.Start,
+		End:   summary.End,
+	}
+
+	for _, result := range summary.Results {
+		digest.Results = append(digest.Results, notifier.DumpResult{
+			Name:     result.Name,
+			Type:     string(result.Type),
+			Tags:     result.Tags,
+			Result:   result.Status,
+			Status:   notificationStatus(result.Status),
+			Duration: result.Duration,
+			Size:     result.Size,
+			Files:    result.Files,
+			Error:    result.Error,
+			LogTail:  result.LogTail,
+		})
+	}
+
+	return digest
+}
+
+func notificationStatus(status string) notifier.Status {
+	switch status {
+	case statusSuccess:
+		return notifier.StatusSuccess
+	case statusFailed:
+		return notifier.StatusError
+	case statusAborted:
+		return notifier.StatusWarning
+	default:
+		return notifier.StatusInfo
+	}
+}
+
+func runInterruption(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timed out"
+	}
+	return "aborted"
+}
+
+func (summary *runSummary) print(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tSTATUS\tDURATION\tSIZE\tERROR")
+	for _, result := range summary.Results {
+		size := "-"
+		if result.Size > 0 {
+			size = formatSize(result.Size)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			result.Name, result.Type, result.Status, result.Duration.Round(time.Millisecond), size, result.Error)
+	}
+	return w.Flush()
+}
+
+func (summary *runSummary) writeJson(path string) error {
+	content, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	content = append(content, '\n')
+
+	if path == "-" {
+		_, err := os.Stdout.Write(content)
+		return err
+	}
+
+	return os.WriteFile(path, content, 0644)
+}
+
+// exitError returns nil when all dumps succeeded, otherwise error with partial or total failure code,
+// aborted dumps count as failed
+func (summary *runSummary) exitError() error {
+	failed := 0
+	for _, result := range summary.Results {
+		if result.Status == statusFailed || result.Status == statusAborted {
+			failed++
+		}
+	}
+
+	switch {
+	case failed == 0:
+		return nil
+	case failed == len(summary.Results):
+		return &exitError{exitTotalFailure, fmt.Errorf("all %d dumps failed", failed)}
+	default:
+		return &exitError{exitPartialFailure, fmt.Errorf("%d of %d dumps failed", failed, len(summary.Results))}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"box/dumper"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestDumpResult_finish(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("exit status of shell command")
+	}
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+
+	tests := []struct {
+		name       string
+		report     dumper.Report
+		err        error
+		status     string
+		exitStatus int
+	}{
+		{name: "dumped", report: dumper.Report{Dumped: true, Pruned: []string{"daily/2026-10-01"}}, status: statusSuccess},
+		{name: "not needed", status: statusSkipped},
+		{name: "dry run", report: dumper.Report{Planned: true}, status: statusPlanned},
+		{name: "locked", err: fmt.Errorf("dump locked: %w", dumper.ErrLocked), status: statusSkipped},
+		{name: "cancelled", err: fmt.Errorf("dump aborted: %w", context.Canceled), status: statusAborted, exitStatus: 1},
+		{name: "timed out", err: fmt.Errorf("dump timed out: %w", context.DeadlineExceeded), status: statusFailed, exitStatus: 1},
+		{name: "command failed", err: fmt.Errorf("%w (failed 2 attempts)", exitErr), status: statusFailed, exitStatus: 3},
+		{name: "other error", err: errors.New("empty dump file"), status: statusFailed, exitStatus: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := dumpResult{Name: "db"}.finish(tt.report, tt.err)
+			if result.Status != tt.status || result.ExitStatus != tt.exitStatus {
+				t.Errorf("finish() status = %s, exit status %d, want %s, %d", result.Status, result.ExitStatus, tt.status, tt.exitStatus)
+			}
+			if !reflect.DeepEqual(result.Pruned, tt.report.Pruned) {
+				t.Errorf("finish() pruned = %v, want %v", result.Pruned, tt.report.Pruned)
+			}
+			if tt.err != nil && result.Error != tt.err.Error() {
+				t.Errorf("finish() error = %q, want %q", result.Error, tt.err)
+			}
+		})
+	}
+}
+
+func TestRunSummary_exitError(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		code     int
+	}{
+		{name: "no dumps"},
+		{name: "all succeeded", statuses: []string{statusSuccess, statusSkipped}},
+		{name: "all skipped", statuses: []string{statusSkipped, statusSkipped}},
+		{name: "dry run", statuses: []string{statusPlanned, statusSkipped}},
+		{name: "some failed", statuses: []string{statusSuccess, statusFailed, statusSkipped}, code: exitPartialFailure},
+		{name: "some aborted", statuses: []string{statusSuccess, statusAborted}, code: exitPartialFailure},
+		{name: "all failed", statuses: []string{statusFailed, statusFailed}, code: exitTotalFailure},
+		{name: "all failed or aborted", statuses: []string{statusFailed, statusAborted}, code: exitTotalFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := &runSummary{}
+			for _, status := range tt.statuses {
+				summary.Results = append(summary.Results, dumpResult{Status: status})
+			}
+
+			err := summary.exitError()
+			code := 0
+			var exit *exitError
+			if errors.As(err, &exit) {
+				code = exit.code
+			} else if err != nil {
+				t.Fatalf("exitError() = %v, want exit code", err)
+			}
+			if code != tt.code {
+				t.Errorf("exit code = %d, want %d", code, tt.code)
+			}
+		})
+	}
+
+	//usage errors do not collide with failures of dumps
+	if exitUsageError == exitPartialFailure || exitUsageError == exitTotalFailure {
+		t.Errorf("usage exit code %d collides with dump failures", exitUsageError)
+	}
+}
+
+func TestRunSummary_writeJson(t *testing.T) {
+	start := time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC)
+	summary := &runSummary{Start: start, End: start.Add(time.Minute), Results: []dumpResult{{
+		Name:      "pg",
+		Type:      dumper.TypePostgres,
+		Status:    statusSuccess,
+		Start:     start,
+		End:       start.Add(time.Minute),
+		Duration:  time.Minute,
+		Seconds:   60,
+		Size:      1024,
+		Checksum:  "abc",
+		Checksums: map[string]string{dumper.HashSha256: "abc"},
+		Files:     []string{"/backup/pg/latest"},
+		Pruned:    []string{"/backup/pg/daily/2026-10-01"},
+		LogTail:   "pg_dump: done",
+	}}}
+
+	path := filepath.Join(t.TempDir(), "summary.json")
+	if err := summary.writeJson(path); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(content, &got); err != nil {
+		t.Fatal(err)
+	}
+	results := got["results"].([]interface{})
+	result := results[0].(map[string]interface{})
+	want := map[string]interface{}{
+		"name":             "pg",
+		"type":             "postgres",
+		"status":           statusSuccess,
+		"start":            "2026-10-19T03:00:00Z",
+		"end":              "2026-10-19T03:01:00Z",
+		"duration_seconds": 60.0,
+		"size":             1024.0,
+		"exit_status":      0.0,
+		"checksum":         "abc",
+		"checksums":        map[string]interface{}{"sha256": "abc"},
+		"files":            []interface{}{"/backup/pg/latest"},
+		"pruned":           []interface{}{"/backup/pg/daily/2026-10-01"},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("summary result = %v, want %v", result, want)
+	}
+	if got["start"] != "2026-10-19T03:00:00Z" || got["end"] != "2026-10-19T03:01:00Z" {
+		t.Errorf("summary start, end = %v, %v", got["start"], got["end"])
+	}
+}
@@ -0,0 +1,605 @@
+package main
+
+import (
+	"box/configuration"
+	"box/dumper"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultListen = "127.0.0.1:8080"
+
+// server shows dumps, their stored files and history, authorized users run dumps and download files
+type server struct {
+	config *configuration.Configuration
+	jobs   *jobRunner
+}
+
+func serveCommand(opts *options, args []string) error {
+	flags := newCommandFlags("serve", "")
+	listen := flags.String("listen", "", "`address` to listen, overrides server.listen")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := readConfiguration(opts)
+	if err != nil {
+		return err
+	}
+	if opts.dryRun {
+		return errors.New("serve does not support dry run")
+	}
+
+	jobs, err := newJobRunner(opts)
+	if err != nil {
+		return err
+	}
+
+	s := &server{config: config, jobs: jobs}
+
+	address := *listen
+	if len(address) == 0 {
+		address = config.Server.Listen
+	}
+	if len(address) == 0 {
+		address = defaultListen
+	}
+
+	httpServer := &http.Server{
+		Addr:              address,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() {
+		if len(config.Server.TlsCert) != 0 {
+			errs <- httpServer.ListenAndServeTLS(config.Server.TlsCert, config.Server.TlsKey)
+		} else {
+			errs <- httpServer.ListenAndServe()
+		}
+	}()
+
+	if !s.authEnabled() {
+		log.Warnf("server username and password are not set, dashboard is read-only without logs")
+	}
+	if len(config.Server.ApiTokens) == 0 {
+		log.Infof("server api-tokens are not set, api is disabled")
+	}
+	log.Infof("serving on %s", address)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Infof("stopping server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Errorf("unable to stop server: %s", err)
+	}
+	jobs.stop()
+
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path, err := splitPath(r.URL.EscapedPath())
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	//api clients authenticate with tokens
+	if len(path) != 0 && path[0] == "api" {
+		s.serveApi(w, r, path[1:])
+		return
+	}
+
+	if s.authEnabled() && !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="box", charset="UTF-8"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case len(path) == 0:
+		s.index(w, r)
+	case len(path) == 2 && path[0] == "dumps":
+		s.dump(w, r, path[1])
+	case len(path) == 3 && path[0] == "dumps" && path[2] == "log":
+		s.artifactLog(w, r, path[1])
+	case len(path) == 3 && path[0] == "dumps" && path[2] == "download":
+		s.download(w, r, path[1])
+	case len(path) == 3 && path[0] == "dumps" && path[2] == "run":
+		s.run(w, r, path[1])
+	case len(path) == 2 && path[0] == "jobs":
+		s.job(w, r, path[1])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// splitPath returns unescaped segments of escaped url path, so names may contain slashes
+func splitPath(escapedPath string) ([]string, error) {
+	var path []string
+	for _, segment := range strings.Split(strings.Trim(escapedPath, "/"), "/") {
+		if len(segment) == 0 {
+			continue
+		}
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, unescaped)
+	}
+	return path, nil
+}
+
+func (s *server) authEnabled() bool {
+	return len(s.config.Server.Username) != 0
+}
+
+func (s *server) authorized(r *http.Request) bool {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	usernameOk := subtle.ConstantTimeCompare([]byte(username), []byte(s.config.Server.Username)) == 1
+	passwordOk := subtle.ConstantTimeCompare([]byte(password), []byte(s.config.Server.Password)) == 1
+	return usernameOk && passwordOk
+}
+
+// requireCredentials refuses action without configured credentials, dashboard without them shows no logs and files
+func (s *server) requireCredentials(w http.ResponseWriter, action string) bool {
+	if s.authEnabled() {
+		return true
+	}
+	http.Error(w, action+" require server username and password", http.StatusForbidden)
+	return false
+}
+
+// sameOrigin rejects requests of other sites, browsers send basic auth credentials with them
+func sameOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); len(site) != 0 && site != "same-origin" && site != "none" {
+		return false
+	}
+	if origin := r.Header.Get("Origin"); len(origin) != 0 {
+		u, err := url.Parse(origin)
+		return err == nil && u.Host == r.Host
+	}
+	return true
+}
+
+func (s *server) dumpConfiguration(name string) (dumper.Configuration, bool) {
+	for _, dump := range s.config.Dumps {
+		if dump.Name == name {
+			return dump, true
+		}
+	}
+	return dumper.Configuration{}, false
+}
+
+func (s *server) findDump(name string) (dumper.Dumper, error) {
+	dump, ok := s.dumpConfiguration(name)
+	if !ok {
+		return nil, fmt.Errorf("dump %s not found", name)
+	}
+	return dumper.New(s.config.Global, dump)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// dumpView is dump with its last results and stored files
+type dumpView struct {
+	Name        string
+	Type        dumper.Type
+	Tags        []string
+	Periods     string
+	Last        *dumpResult
+	LastSuccess *dumpResult
+	NextDue     time.Time
+	Due         bool
+	Running     bool
+	Count       int
+	Size        int64
+	Newest      *dumper.Artifact
+	Error       string
+}
+
+type artifactView struct {
+	dumper.Artifact
+	Reference string
+	Sha256    string
+	HasLog    bool
+}
+
+func (s *server) dumpView(dump dumper.Configuration, d dumper.Dumper, results []dumpResult) (dumpView, []dumper.Artifact) {
+	view := dumpView{
+		Name:    dump.Name,
+		Type:    dump.Type,
+		Tags:    dump.Tags,
+		Periods: enabledPeriods(dump),
+		Running: s.jobs.running(dump.Name),
+	}
+
+	for i := len(results) - 1; i >= 0; i-- {
+		result := results[i]
+		if result.Name != dump.Name {
+			continue
+		}
+		if view.Last == nil {
+			view.Last = &results[i]
+		}
+		if result.Status == statusSuccess {
+			view.LastSuccess = &results[i]
+			break
+		}
+	}
+
+	if d == nil {
+		return view, nil
+	}
+
+	view.NextDue = d.NextDue()
+	view.Due = !view.NextDue.IsZero() && !view.NextDue.After(time.Now())
+
+	artifacts, err := d.Inventory()
+	if err != nil {
+		view.Error = err.Error()
+		return view, nil
+	}
+	view.Count = len(artifacts)
+	for i, a := range artifacts {
+		view.Size += a.Size
+		if view.Newest == nil || a.Time.After(view.Newest.Time) {
+			view.Newest = &artifacts[i]
+		}
+	}
+
+	return view, artifacts
+}
+
+func (s *server) index(w http.ResponseWriter, r *http.Request) {
+	results, err := readCatalog(catalogFileName(s.config), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var dumps []dumpView
+	for _, dump := range s.config.Dumps {
+		d, err := dumper.New(s.config.Global, dump)
+		view, _ := s.dumpView(dump, d, results)
+		if err != nil {
+			view.Error = err.Error()
+		}
+		dumps = append(dumps, view)
+	}
+
+	s.render(w, "index", map[string]interface{}{
+		"Dumps": dumps,
+		"Jobs":  s.jobs.list(),
+	})
+}
+
+func (s *server) dump(w http.ResponseWriter, r *http.Request, name string) {
+	dump, ok := s.dumpConfiguration(name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	d, dumperErr := dumper.New(s.config.Global, dump)
+
+	results, err := readCatalog(catalogFileName(s.config), []string{escapePattern(name)})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	view, artifacts := s.dumpView(dump, d, results)
+	if dumperErr != nil {
+		view.Error = dumperErr.Error()
+	}
+
+	var files []artifactView
+	for _, a := range artifacts {
+		file := artifactView{Artifact: a, Reference: a.Reference()}
+		if checksums, err := a.Checksums(); err == nil {
+			file.Sha256 = checksums[dumper.HashSha256]
+		}
+		if _, err := os.Stat(a.LogPath()); err == nil {
+			file.HasLog = true
+		}
+		files = append(files, file)
+	}
+
+	//newest first
+	var history []dumpResult
+	for i := len(results) - 1; i >= 0 && len(history) < 20; i-- {
+		history = append(history, results[i])
+	}
+
+	var jobs []job
+	for _, j := range s.jobs.list() {
+		if j.hasDump(name) {
+			jobs = append(jobs, j)
+		}
+	}
+
+	s.render(w, "dump", map[string]interface{}{
+		"Dump":          view,
+		"Files":         files,
+		"History":       history,
+		"Jobs":          jobs,
+		"Authenticated": s.authEnabled(),
+	})
+}
+
+// findArtifact returns stored dump by reference: latest, period or period/file
+func (s *server) findArtifact(name, reference string) (dumper.Artifact, error) {
+	d, err := s.findDump(name)
+	if err != nil {
+		return dumper.Artifact{}, err
+	}
+	return d.Find(reference)
+}
+
+func (s *server) artifactLog(w http.ResponseWriter, r *http.Request, name string) {
+	//logs of dump commands may contain connection strings and credential errors
+	if !s.requireCredentials(w, "logs") {
+		return
+	}
+	a, err := s.findArtifact(name, r.URL.Query().Get("file"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeFile(w, r, a.LogPath())
+}
+
+func (s *server) download(w http.ResponseWriter, r *http.Request, name string) {
+	if !s.requireCredentials(w, "downloads") {
+		return
+	}
+	a, err := s.findArtifact(name, r.URL.Query().Get("file"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	file, err := os.Open(a.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	fileName := fmt.Sprintf("%s-%s", name, strings.ReplaceAll(a.Reference(), "/", "-"))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	log.Infof("%s: %s downloaded by %s", name, a.Reference(), r.RemoteAddr)
+	http.ServeContent(w, r, fileName, a.Time, file)
+}
+
+func (s *server) run(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requireCredentials(w, "runs") {
+		return
+	}
+	if !sameOrigin(r) {
+		http.Error(w, "cross-site request", http.StatusForbidden)
+		return
+	}
+	if _, ok := s.dumpConfiguration(name); !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	//dump is locked by running job, another run would be skipped, so the running job is shown
+	j, err := s.jobs.start("run", []string{name}, nil)
+	if err != nil && !errors.Is(err, errJobRunning) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/jobs/"+url.PathEscape(j.Id), http.StatusSeeOther)
+}
+
+func (s *server) job(w http.ResponseWriter, r *http.Request, id string) {
+	if !s.requireCredentials(w, "jobs") {
+		return
+	}
+	j, ok := s.jobs.get(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.render(w, "job", map[string]interface{}{
+		"Job":     j,
+		"Refresh": j.End == nil,
+	})
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+func (s *server) render(w http.ResponseWriter, name string, data map[string]interface{}) {
+	data["Title"] = "box"
+	data["Now"] = time.Now()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := pages.ExecuteTemplate(w, name, data); err != nil {
+		log.Errorf("unable to render page %s: %s", name, err)
+	}
+}
+
+var pages = template.Must(template.New("pages").Funcs(template.FuncMap{
+	"size": formatSize,
+	"time": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Local().Format("2006-01-02 15:04:05")
+	},
+	"age": func(t time.Time) string {
+		return formatAge(time.Since(t))
+	},
+	"duration": func(seconds float64) time.Duration {
+		return time.Duration(seconds * float64(time.Second)).Round(time.Millisecond)
+	},
+	"join":       strings.Join,
+	"pathEscape": url.PathEscape,
+	"short": func(checksum string) string {
+		if len(checksum) > 16 {
+			return checksum[:16] + "…"
+		}
+		return checksum
+	},
+}).Parse(pageTemplates))
+
+const pageTemplates = `
+{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+{{if .Refresh}}<meta http-equiv="refresh" content="3">{{end}}
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { text-align: left; padding: 0.3em 0.8em; border-bottom: 1px solid #ddd; }
+td.number { text-align: right; }
+.success { color: #00aa00; }
+.failed, .aborted, .error { color: #aa0000; }
+.skipped, .running { color: #dd8800; }
+pre { background: #f4f4f4; padding: 1em; overflow: auto; }
+code { font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1><a href="/">box</a></h1>
+{{end}}
+
+{{define "footer"}}<p><small>{{time .Now}}</small></p>
+</body>
+</html>
+{{end}}
+
+{{define "status"}}{{if .}}<span class="{{.Status}}">{{.Status}}</span> {{time .Start}}{{else}}-{{end}}{{end}}
+
+{{define "jobs"}}{{if .}}
+<h2>Jobs</h2>
+<table>
+<tr><th>Job</th><th>Command</th><th>Dumps</th><th>Status</th><th>Start</th><th>Exit code</th></tr>
+{{range .}}<tr>
+<td><a href="/jobs/{{pathEscape .Id}}">{{.Id}}</a></td>
+<td>{{.Command}} {{join .Args " "}}</td>
+<td>{{join .Dumps ", "}}</td>
+<td class="{{.Status}}">{{.Status}}</td>
+<td>{{time .Start}}</td>
+<td>{{if .End}}{{.ExitCode}}{{end}}</td>
+</tr>{{end}}
+</table>
+{{end}}{{end}}
+
+{{define "index"}}{{template "header" .}}
+<table>
+<tr><th>Dump</th><th>Type</th><th>Tags</th><th>Periods</th><th>Last run</th><th>Last success</th><th>Next due</th><th>Newest</th><th>Files</th><th>Size</th></tr>
+{{range .Dumps}}<tr>
+<td><a href="/dumps/{{pathEscape .Name}}">{{.Name}}</a></td>
+<td>{{.Type}}</td>
+<td>{{join .Tags ", "}}</td>
+<td>{{.Periods}}</td>
+<td>{{if .Running}}<span class="running">running</span>{{else}}{{template "status" .Last}}{{end}}</td>
+<td>{{if .LastSuccess}}{{time .LastSuccess.Start}}{{else}}-{{end}}</td>
+<td>{{if .Due}}now{{else}}{{time .NextDue}}{{end}}</td>
+<td>{{if .Newest}}{{.Newest.Reference}}, {{age .Newest.Time}} ago{{else}}-{{end}}</td>
+<td class="number">{{.Count}}</td>
+<td class="number">{{size .Size}}</td>
+</tr>{{if .Error}}<tr><td></td><td colspan="9" class="error">{{.Error}}</td></tr>{{end}}
+{{end}}
+</table>
+{{template "jobs" .Jobs}}
+{{template "footer" .}}{{end}}
+
+{{define "dump"}}{{template "header" .}}
+{{with .Dump}}
+<h2>{{.Name}} <small>({{.Type}})</small></h2>
+<p>
+Periods: {{.Periods}}<br>
+Last run: {{if .Running}}<span class="running">running</span>{{else}}{{template "status" .Last}}{{end}}<br>
+Last success: {{if .LastSuccess}}{{time .LastSuccess.Start}}{{else}}-{{end}}<br>
+Next due: {{if .Due}}now{{else}}{{time .NextDue}}{{end}}
+</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{end}}
+{{if .Authenticated}}<form method="post" action="/dumps/{{pathEscape .Dump.Name}}/run"><button type="submit">Run now</button></form>{{end}}
+
+<h2>Files</h2>
+<table>
+<tr><th>File</th><th>Time</th><th>Size</th><th>SHA256</th><th></th></tr>
+{{$name := .Dump.Name}}{{$authenticated := .Authenticated}}
+{{range .Files}}<tr>
+<td>{{.Reference}}</td>
+<td>{{time .Time}}</td>
+<td class="number">{{size .Size}}</td>
+<td><code title="{{.Sha256}}">{{short .Sha256}}</code></td>
+<td>{{if $authenticated}}{{if .HasLog}}<a href="/dumps/{{pathEscape $name}}/log?file={{.Reference}}">log</a>{{end}}
+<a href="/dumps/{{pathEscape $name}}/download?file={{.Reference}}">download</a>{{end}}</td>
+</tr>{{else}}<tr><td colspan="5">no files</td></tr>{{end}}
+</table>
+
+{{template "jobs" .Jobs}}
+
+<h2>History</h2>
+<table>
+<tr><th>Start</th><th>Status</th><th>Duration</th><th>Size</th><th>Exit code</th><th>Attempts</th><th>Error</th></tr>
+{{range .History}}<tr>
+<td>{{time .Start}}</td>
+<td class="{{.Status}}">{{.Status}}</td>
+<td>{{duration .Seconds}}</td>
+<td class="number">{{if .Size}}{{size .Size}}{{else}}-{{end}}</td>
+<td>{{.ExitStatus}}</td>
+<td>{{.Attempts}}</td>
+<td>{{.Error}}</td>
+</tr>{{else}}<tr><td colspan="7">no runs</td></tr>{{end}}
+</table>
+{{template "footer" .}}{{end}}
+
+{{define "job"}}{{template "header" .}}
+{{with .Job}}
+<h2>Job {{.Id}}</h2>
+<p>
+Command: {{.Command}} {{join .Args " "}}<br>
+Dumps: {{range $i, $name := .Dumps}}{{if $i}}, {{end}}<a href="/dumps/{{pathEscape $name}}">{{$name}}</a>{{end}}<br>
+Status: <span class="{{.Status}}">{{.Status}}</span>{{if .End}}, exit code {{.ExitCode}}{{end}}<br>
+Start: {{time .Start}}{{if .End}}<br>End: {{time .End}}{{end}}
+</p>
+<pre>{{.Output}}</pre>
+{{end}}
+{{template "footer" .}}{{end}}
+`
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"box/configuration"
+	"box/dumper"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// newTestServer serves dump files with latest dump, jobs run shell script instead of box
+func newTestServer(t *testing.T, serverConfiguration configuration.ServerConfiguration, script string) *server {
+	directory := t.TempDir()
+	config := &configuration.Configuration{
+		Global: dumper.GlobalConfiguration{
+			Path:          filepath.Join(directory, "dumps"),
+			TmpPath:       filepath.Join(directory, "tmp"),
+			TarExecutable: "tar",
+		},
+		Server: serverConfiguration,
+		Dumps: []dumper.Configuration{
+			{Name: "files", Type: dumper.TypeTar, Latest: true, Vars: map[string]string{"path": directory}},
+		},
+	}
+
+	latest := filepath.Join(config.Global.Path, "files", dumper.PeriodLatest)
+	if err := os.MkdirAll(filepath.Dir(latest), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for fileName, content := range map[string]string{latest: "dump", latest + ".log": "dump log"} {
+		if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &server{config: config, jobs: &jobRunner{executable: "/bin/sh", args: []string{"-c", script, "box"}}}
+}
+
+func serve(s *server, method, target string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for key, values := range header {
+		r.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func basicAuth(username, password string) http.Header {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.SetBasicAuth(username, password)
+	return r.Header
+}
+
+func TestServer_auth(t *testing.T) {
+	s := newTestServer(t, configuration.ServerConfiguration{Username: "admin", Password: "secret"}, "true")
+
+	w := serve(s, http.MethodGet, "/", nil)
+	if w.Code != http.StatusUnauthorized || !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Basic") {
+		t.Errorf("expected basic auth challenge, got %d %q", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+
+	for _, path := range []string{"/", "/dumps/files", "/dumps/files/log?file=latest", "/dumps/files/download?file=latest"} {
+		if w := serve(s, http.MethodGet, path, basicAuth("admin", "wrong")); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected wrong password rejected, got %d", path, w.Code)
+		}
+		if w := serve(s, http.MethodGet, path, basicAuth("admin", "secret")); w.Code != http.StatusOK {
+			t.Errorf("%s: expected %d, got %d", path, http.StatusOK, w.Code)
+		}
+	}
+
+	w = serve(s, http.MethodGet, "/dumps/files/download?file=latest", basicAuth("admin", "secret"))
+	if body, _ := io.ReadAll(w.Body); string(body) != "dump" || !strings.Contains(w.Header().Get("Content-Disposition"), "attachment") {
+		t.Errorf("expected dump download, got %q %q", body, w.Header().Get("Content-Disposition"))
+	}
+}
+
+func TestServer_readOnly(t *testing.T) {
+	s := newTestServer(t, configuration.ServerConfiguration{}, "true")
+
+	for _, path := range []string{"/", "/dumps/files"} {
+		if w := serve(s, http.MethodGet, path, nil); w.Code != http.StatusOK {
+			t.Errorf("%s: expected %d, got %d", path, http.StatusOK, w.Code)
+		}
+	}
+	for _, path := range []string{"/dumps/files/download?file=latest", "/dumps/files/log?file=latest", "/jobs/1"} {
+		if w := serve(s, http.MethodGet, path, nil); w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected %d, got %d", path, http.StatusForbidden, w.Code)
+		}
+	}
+	if w := serve(s, http.MethodPost, "/dumps/files/run", nil); w.Code != http.StatusForbidden {
+		t.Errorf("expected run refused, got %d", w.Code)
+	}
+	if w := serve(s, http.MethodGet, "/dumps/files", nil); strings.Contains(w.Body.String(), "download?") {
+		t.Error("expected no download links")
+	}
+	if jobs := s.jobs.list(); len(jobs) != 0 {
+		t.Errorf("expected no jobs, got %d", len(jobs))
+	}
+}
+
+func TestServer_run(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("jobs run shell script")
+	}
+	s := newTestServer(t, configuration.ServerConfiguration{Username: "admin", Password: "secret"}, "true")
+	defer s.jobs.stop()
+
+	crossSite := []http.Header{
+		{"Origin": {"http://evil.example.com"}},
+		{"Sec-Fetch-Site": {"cross-site"}},
+		{"Sec-Fetch-Site": {"same-site"}},
+	}
+	for _, header := range crossSite {
+		for key, values := range basicAuth("admin", "secret") {
+			header[key] = values
+		}
+		if w := serve(s, http.MethodPost, "/dumps/files/run", header); w.Code != http.StatusForbidden {
+			t.Errorf("%v: expected cross-site run refused, got %d", header, w.Code)
+		}
+	}
+	if jobs := s.jobs.list(); len(jobs) != 0 {
+		t.Fatalf("expected no jobs, got %d", len(jobs))
+	}
+
+	if w := serve(s, http.MethodGet, "/dumps/files/run", basicAuth("admin", "secret")); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected GET refused, got %d", w.Code)
+	}
+	if w := serve(s, http.MethodPost, "/dumps/other/run", basicAuth("admin", "secret")); w.Code != http.StatusNotFound {
+		t.Errorf("expected unknown dump, got %d", w.Code)
+	}
+
+	header := basicAuth("admin", "secret")
+	header.Set("Origin", "http://example.com")
+	w := serve(s, http.MethodPost, "/dumps/files/run", header)
+	if w.Code != http.StatusSeeOther || !strings.HasPrefix(w.Header().Get("Location"), "/jobs/") {
+		t.Fatalf("expected redirect to job, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := serve(s, http.MethodGet, w.Header().Get("Location"), basicAuth("admin", "secret")); w.Code != http.StatusOK {
+		t.Errorf("expected job page, got %d", w.Code)
+	}
+}
+
+func TestServer_findArtifact(t *testing.T) {
+	s := newTestServer(t, configuration.ServerConfiguration{Username: "admin", Password: "secret"}, "true")
+
+	for _, path := range []string{
+		"/dumps/files/download?file=../..",
+		"/dumps/files/download?file=../../files/latest",
+		"/dumps/files/download?file=/etc/passwd",
+		"/dumps/files/download",
+		"/dumps/files/log?file=..%2F..%2Ftmp",
+		"/dumps/..%2Ffiles/download?file=latest",
+		"/dumps/other/download?file=latest",
+	} {
+		if w := serve(s, http.MethodGet, path, basicAuth("admin", "secret")); w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected %d, got %d", path, http.StatusNotFound, w.Code)
+		}
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		{path: "/", want: nil},
+		{path: "/dumps/files/", want: []string{"dumps", "files"}},
+		{path: "//dumps//files", want: []string{"dumps", "files"}},
+		{path: "/dumps/a%2Fb/log", want: []string{"dumps", "a/b", "log"}},
+		{path: "/dumps/%zz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := splitPath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   bool
+	}{
+		{name: "no headers", header: http.Header{}, want: true},
+		{name: "same origin", header: http.Header{"Origin": {"http://example.com"}, "Sec-Fetch-Site": {"same-origin"}}, want: true},
+		{name: "typed url", header: http.Header{"Sec-Fetch-Site": {"none"}}, want: true},
+		{name: "other origin", header: http.Header{"Origin": {"http://example.com.evil.com"}}},
+		{name: "null origin", header: http.Header{"Origin": {"null"}}},
+		{name: "cross site", header: http.Header{"Sec-Fetch-Site": {"cross-site"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "http://example.com/dumps/files/run", nil)
+			r.Header = tt.header
+			if got := sameOrigin(r); got != tt.want {
+				t.Errorf("sameOrigin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}